@@ -1,10 +1,20 @@
 package bDB
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
 	"fmt"
 	"github.com/dgraph-io/badger"
+	"github.com/dgraph-io/badger/y"
 	"log"
+	"math"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 )
 
 // ====================================
@@ -24,6 +34,16 @@ const  (
 type bDBLogger struct {
 	*log.Logger
 	level int
+
+	// value log replay of the last open, picked up from badger's info logs
+	mu         sync.Mutex
+	replayed   []replayedFile
+	replayTook time.Duration
+}
+
+type replayedFile struct {
+	fid    uint32
+	offset uint32
 }
 
 func (l *bDBLogger) Errorf(f string, v ...interface{}) {
@@ -39,6 +59,18 @@ func (l *bDBLogger) Warningf(f string, v ...interface{}) {
 }
 
 func (l *bDBLogger) Infof(f string, v ...interface{}) {
+	if strings.HasPrefix(f, "Replaying file id:") && len(v) == 2 {
+		fid, _ := v[0].(uint32)
+		offset, _ := v[1].(uint32)
+		l.mu.Lock()
+		l.replayed = append(l.replayed, replayedFile{fid, offset})
+		l.mu.Unlock()
+	} else if strings.HasPrefix(f, "Replay took:") && len(v) == 1 {
+		took, _ := v[0].(time.Duration)
+		l.mu.Lock()
+		l.replayTook += took
+		l.mu.Unlock()
+	}
 	if l.level <= INFO {
 		l.Printf("INFO: "+f, v...)
 	}
@@ -51,12 +83,45 @@ func (l *bDBLogger) Debugf(f string, v ...interface{}) {
 }
 
 
+// ====================================
+//
+//	Merge functions
+//
+// ====================================
+
+// AddUint64 treats both values as big-endian uint64 and adds them.
+func AddUint64(existingVal, newVal []byte) []byte {
+	var sum uint64
+	if len(existingVal) == 8 {
+		sum = binary.BigEndian.Uint64(existingVal)
+	}
+	if len(newVal) == 8 {
+		sum += binary.BigEndian.Uint64(newVal)
+	}
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, sum)
+	return buf
+}
+
+// Append concatenates the new value to the existing one.
+func Append(existingVal, newVal []byte) []byte {
+	return append(append([]byte{}, existingVal...), newVal...)
+}
+
+var MergeFuncs = map[string]badger.MergeFunc{
+	"add":    AddUint64,
+	"append": Append,
+}
+
 // ====================================
 //        BadgerDB simple wrapper
 // ====================================
 
 type BadgerDBWrapper struct {
-	db     *badger.DB
+	db      *badger.DB
+	opt     badger.Options
+	managed bool // opened with badger.OpenManaged
+	logger  *bDBLogger
 }
 
 func MakeDB() (*BadgerDBWrapper) {
@@ -64,6 +129,16 @@ func MakeDB() (*BadgerDBWrapper) {
 }
 
 func (d *BadgerDBWrapper) Open(opt badger.Options) error {
+	return d.open(opt, false)
+}
+
+// OpenManaged opens the db in managed mode, where the caller supplies the
+// read and commit timestamps of every transaction.
+func (d *BadgerDBWrapper) OpenManaged(opt badger.Options) error {
+	return d.open(opt, true)
+}
+
+func (d *BadgerDBWrapper) open(opt badger.Options, managed bool) error {
 	var err error
 	var logFile *os.File
 	if _,err := os.Stat(opt.Dir); os.IsNotExist(err) {
@@ -81,13 +156,54 @@ func (d *BadgerDBWrapper) Open(opt badger.Options) error {
 	}
 	var defaultLogger = &bDBLogger{Logger: log.New(logFile, "[BadgerDB]", log.LstdFlags), level: WARNING}
 	opt.Logger = defaultLogger
-	if d.db, err = badger.Open(opt); err != nil {
+	d.logger = defaultLogger
+	if d.db, err = openDB(opt, managed); err != nil {
 		return err
 	}
+	d.opt = opt
+	d.managed = managed
 	return nil
 }
 
-func (d *BadgerDBWrapper) Put(key, value string, f ...func(*badger.Txn) error) error {
+func openDB(opt badger.Options, managed bool) (*badger.DB, error) {
+	if managed {
+		return badger.OpenManaged(opt)
+	}
+	return badger.Open(opt)
+}
+
+func (d *BadgerDBWrapper) Managed() bool {
+	return d.managed
+}
+
+// NewWriteBatchAt returns a write batch committing at commitTs. Managed mode only.
+func (d *BadgerDBWrapper) NewWriteBatchAt(commitTs uint64) *badger.WriteBatch {
+	return d.db.NewWriteBatchAt(commitTs)
+}
+
+// GetAt reads key as of readTs and returns the version it found. Managed
+// mode only.
+func (d *BadgerDBWrapper) GetAt(key string, readTs uint64) (value string, version uint64, err error) {
+	txn := d.db.NewTransactionAt(readTs, false)
+	defer txn.Discard()
+	item, err := txn.Get([]byte(key))
+	if err != nil {
+		return "", 0, err
+	}
+	err = item.Value(func(val []byte) error {
+		value = string(val)
+		return nil
+	})
+	return value, item.Version(), err
+}
+
+// SetDiscardTs lets compactions drop versions at or below ts beyond
+// NumVersionsToKeep. Managed mode only.
+func (d *BadgerDBWrapper) SetDiscardTs(ts uint64) {
+	d.db.SetDiscardTs(ts)
+}
+
+func (d *BadgerDBWrapper) Put(key, value string) error {
 	wb := d.db.NewWriteBatch()
 	defer wb.Cancel()
 	if err := wb.SetEntry(badger.NewEntry([]byte(key), []byte(value)).WithMeta(0)); err != nil {
@@ -103,10 +219,212 @@ func (d *BadgerDBWrapper) NewWriteBatch() *badger.WriteBatch{
 	return d.db.NewWriteBatch()
 }
 
+// NewBatch returns a transaction if atomic is set, or else a WriteBatch.
+func (d *BadgerDBWrapper) NewBatch(atomic bool) Batch {
+	if atomic {
+		return &badgerTxnBatch{db: d.db}
+	}
+	return &badgerWriteBatch{db: d.db, wb: d.db.NewWriteBatch()}
+}
+
+type badgerWriteBatch struct {
+	db *badger.DB
+	wb *badger.WriteBatch
+}
+
+func (b *badgerWriteBatch) Set(key, value []byte, ttl time.Duration) error {
+	entry := badger.NewEntry(key, value).WithMeta(0)
+	if ttl > 0 {
+		entry = entry.WithTTL(ttl)
+	}
+	return b.wb.SetEntry(entry)
+}
+
+func (b *badgerWriteBatch) Delete(key []byte) error {
+	return b.wb.Delete(key)
+}
+
+func (b *badgerWriteBatch) Commit() error {
+	// a flushed WriteBatch is done with, so go on with a new one
+	err := b.wb.Flush()
+	b.wb = b.db.NewWriteBatch()
+	return err
+}
+
+func (b *badgerWriteBatch) Cancel() {
+	b.wb.Cancel()
+}
+
+type badgerTxnBatch struct {
+	db      *badger.DB
+	pending []*badger.Entry
+	deletes []bool // pending[i] is a delete
+}
+
+func (b *badgerTxnBatch) Set(key, value []byte, ttl time.Duration) error {
+	entry := badger.NewEntry(key, value).WithMeta(0)
+	if ttl > 0 {
+		entry = entry.WithTTL(ttl)
+	}
+	b.pending = append(b.pending, entry)
+	b.deletes = append(b.deletes, false)
+	return nil
+}
+
+func (b *badgerTxnBatch) Delete(key []byte) error {
+	b.pending = append(b.pending, &badger.Entry{Key: key})
+	b.deletes = append(b.deletes, true)
+	return nil
+}
+
+// Commit writes the pending entries in one transaction. Like WriteBatch, it
+// commits what fits and goes on in a new transaction when they are too many
+// for one.
+func (b *badgerTxnBatch) Commit() error {
+	if len(b.pending) == 0 {
+		return nil
+	}
+	defer func() {
+		b.pending = b.pending[:0]
+		b.deletes = b.deletes[:0]
+	}()
+	txn := b.db.NewTransaction(true)
+	defer func() { txn.Discard() }()
+	inTxn := 0
+	for i := 0; i < len(b.pending); i++ {
+		var err error
+		if b.deletes[i] {
+			err = txn.Delete(b.pending[i].Key)
+		} else {
+			err = txn.SetEntry(b.pending[i])
+		}
+		if err == badger.ErrTxnTooBig && inTxn > 0 {
+			if err = txn.Commit(); err != nil {
+				return err
+			}
+			txn = b.db.NewTransaction(true)
+			inTxn = 0
+			i--
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		inTxn++
+	}
+	return txn.Commit()
+}
+
+func (b *badgerTxnBatch) Cancel() {
+	b.pending = nil
+	b.deletes = nil
+}
+
+// badgerIterator is a badger iterator together with the read-only
+// transaction it belongs to.
+type badgerIterator struct {
+	txn    *badger.Txn
+	iter   *badger.Iterator
+	prefix []byte
+}
+
+func (d *BadgerDBWrapper) NewIterator(opt IteratorOptions) Iterator {
+	var txn *badger.Txn
+	if d.managed {
+		// read the latest versions, as db.View does in managed mode
+		txn = d.db.NewTransactionAt(math.MaxUint64, false)
+	} else {
+		txn = d.db.NewTransaction(false)
+	}
+	iterOpt := badger.DefaultIteratorOptions
+	iterOpt.Reverse = opt.Reverse
+	iterOpt.Prefix = opt.Prefix
+	iterOpt.PrefetchValues = opt.PrefetchValues
+	iterOpt.PrefetchSize = opt.PrefetchSize
+	return &badgerIterator{txn: txn, iter: txn.NewIterator(iterOpt), prefix: opt.Prefix}
+}
+
+func (it *badgerIterator) Rewind() {
+	it.iter.Rewind()
+}
+
+func (it *badgerIterator) Seek(key []byte) {
+	it.iter.Seek(key)
+}
+
+func (it *badgerIterator) Valid() bool {
+	// badger only uses the prefix to skip tables, Valid doesn't check it
+	return it.iter.ValidForPrefix(it.prefix)
+}
+
+func (it *badgerIterator) Next() {
+	it.iter.Next()
+}
+
+func (it *badgerIterator) Key() []byte {
+	return it.iter.Item().Key()
+}
+
+func (it *badgerIterator) Value(f func(value []byte) error) error {
+	return it.iter.Item().Value(f)
+}
+
+func (it *badgerIterator) ValueInVlog() bool {
+	return ValueInVlog(it.iter.Item())
+}
+
+func (it *badgerIterator) Close() {
+	it.iter.Close()
+	it.txn.Discard()
+}
+
 func (d *BadgerDBWrapper) DoView(f func(*badger.Txn) error) error {
 	return d.db.View(f)
 }
 
+// MultiGet looks up all keys inside one read transaction. values[i] is
+// empty if keys[i] was not found.
+func (d *BadgerDBWrapper) MultiGet(keys []string) (values []string, found int, err error) {
+	values = make([]string, len(keys))
+	err = d.db.View(func(txn *badger.Txn) error {
+		for i, key := range keys {
+			item, err := txn.Get([]byte(key))
+			if err == badger.ErrKeyNotFound {
+				continue
+			} else if err != nil {
+				return err
+			}
+			err = item.Value(func(val []byte) error {
+				values[i] = string(val)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			found++
+		}
+		return nil
+	})
+	return
+}
+
+// ValueInVlog tells whether the value of item lives in the value log rather
+// than inline in the LSM tree, without reading the value. For inline values
+// EstimatedSize is exactly key plus value, while a value pointer also counts
+// the vlog entry header, the timestamp and the checksum.
+func ValueInVlog(item *badger.Item) bool {
+	return item.EstimatedSize() > item.KeySize()+item.ValueSize()
+}
+
+func (d *BadgerDBWrapper) DoUpdate(f func(*badger.Txn) error) error {
+	return d.db.Update(f)
+}
+
+// NewTransaction starts a transaction which the caller must Commit or Discard.
+func (d *BadgerDBWrapper) NewTransaction(update bool) *badger.Txn {
+	return d.db.NewTransaction(update)
+}
+
 func (d *BadgerDBWrapper) Get(key string) (string, error) {
 	var value string
 	err := d.db.View(func(txn *badger.Txn) error {
@@ -120,13 +438,242 @@ func (d *BadgerDBWrapper) Get(key string) (string, error) {
 		})
 		return err
 	})
+	if err == badger.ErrKeyNotFound {
+		err = ErrNotFound
+	}
 	return value, err
 }
 
+// GetMerged reads key the way MergeOperator.Get does, merging all of its
+// versions with f, but without starting the compactions of a MergeOperator.
+func (d *BadgerDBWrapper) GetMerged(key string, f badger.MergeFunc) ([]byte, error) {
+	var value []byte
+	var versions int
+	err := d.db.View(func(txn *badger.Txn) error {
+		opt := badger.DefaultIteratorOptions
+		opt.AllVersions = true
+		it := txn.NewKeyIterator([]byte(key), opt)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			versions++
+			err := item.Value(func(val []byte) error {
+				// val is older than everything merged into value so far
+				if versions == 1 {
+					value = append([]byte{}, val...)
+				} else {
+					value = f(val, value)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if item.DiscardEarlierVersions() {
+				break
+			}
+		}
+		return nil
+	})
+	if err == nil && versions == 0 {
+		err = ErrNotFound
+	}
+	return value, err
+}
+
+func (d *BadgerDBWrapper) Delete(key string) error {
+	return d.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(key))
+	})
+}
+
+// Reasons of a Get miss, see MissReason
+const (
+	MissNotFound int = iota // no version of the key is left
+	MissDeleted             // the newest version is a delete marker
+	MissExpired             // the newest version has expired
+)
+
+// MissReason tells why Get does not find key: whether the newest version of
+// the key still in the LSM tree is a delete marker or has expired. It opens an
+// iterator over all versions, so it is much slower than Get.
+func (d *BadgerDBWrapper) MissReason(key string) (miss int, err error) {
+	miss = MissNotFound
+	err = d.db.View(func(txn *badger.Txn) error {
+		iterOpt := badger.IteratorOptions{AllVersions: true, Prefix: []byte(key)}
+		iter := txn.NewIterator(iterOpt)
+		defer iter.Close()
+		iter.Seek([]byte(key))
+		if iter.ValidForPrefix([]byte(key)) && bytes.Equal(iter.Item().Key(), []byte(key)) {
+			item := iter.Item()
+			if expiresAt := item.ExpiresAt(); expiresAt != 0 && expiresAt <= uint64(time.Now().Unix()) {
+				miss = MissExpired
+			} else if item.IsDeletedOrExpired() {
+				miss = MissDeleted
+			}
+		}
+		return nil
+	})
+	return
+}
+
+// GetMergeOperator returns a merge operator for key that compacts its
+// operands with f every dur. The caller must Stop it before closing the db.
+func (d *BadgerDBWrapper) GetMergeOperator(key string, f badger.MergeFunc, dur time.Duration) *badger.MergeOperator {
+	return d.db.GetMergeOperator([]byte(key), f, dur)
+}
+
+func (d *BadgerDBWrapper) NewStream() *badger.Stream {
+	return d.db.NewStream()
+}
+
+// KeySplits returns the boundaries Stream uses to split the key ranges it
+// iterates over concurrently, without their timestamps.
+func (d *BadgerDBWrapper) KeySplits(prefix []byte) []string {
+	splits := d.db.KeySplits(prefix)
+	for i, split := range splits {
+		splits[i] = string(y.ParseKey([]byte(split)))
+	}
+	return splits
+}
+
+// Backup writes every version newer than or equal to since to path and
+// returns the version to pass as since to the next incremental backup.
+func (d *BadgerDBWrapper) Backup(path string, since uint64) (uint64, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	w := bufio.NewWriterSize(f, 1<<20)
+	maxVersion, err := d.db.Backup(w, since)
+	if err != nil {
+		return 0, err
+	}
+	if err = w.Flush(); err != nil {
+		return 0, err
+	}
+	if err = f.Sync(); err != nil {
+		return 0, err
+	}
+	// an empty backup reports version 0, nothing changed since then
+	if maxVersion == 0 {
+		return since, nil
+	}
+	return maxVersion + 1, nil
+}
+
+// Load restores a backup written by Backup. The db must not be serving any
+// other transactions meanwhile.
+func (d *BadgerDBWrapper) Load(path string, maxPendingWrites int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return d.db.Load(f, maxPendingWrites)
+}
+
+// KeyCount counts the live keys without fetching values.
+func (d *BadgerDBWrapper) KeyCount() (int, error) {
+	n := 0
+	err := d.db.View(func(txn *badger.Txn) error {
+		iterOpt := badger.DefaultIteratorOptions
+		iterOpt.PrefetchValues = false
+		iter := txn.NewIterator(iterOpt)
+		defer iter.Close()
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// Subscribe calls cb with the updates of keys under prefixes until ctx is
+// done.
+func (d *BadgerDBWrapper) Subscribe(ctx context.Context, cb func(kv *badger.KVList) error, prefixes ...[]byte) error {
+	return d.db.Subscribe(ctx, cb, prefixes...)
+}
+
+// GetSequence returns a sequence leasing bandwidth integers at a time. The
+// caller must Release it before closing the db to avoid losing the lease.
+func (d *BadgerDBWrapper) GetSequence(key string, bandwidth uint64) (*badger.Sequence, error) {
+	return d.db.GetSequence([]byte(key), bandwidth)
+}
+
 func (d *BadgerDBWrapper) VlogGC(threshold float64) error{
 	return d.db.RunValueLogGC(threshold)
 }
 
+// Reopen closes the db, which flushes the memtables, and opens it again with
+// the same options.
+func (d *BadgerDBWrapper) Reopen() error {
+	if err := d.db.Close(); err != nil {
+		return err
+	}
+	var err error
+	d.logger.resetReplay()
+	d.db, err = openDB(d.opt, d.managed)
+	return err
+}
+
+func (l *bDBLogger) resetReplay() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.replayed = nil
+	l.replayTook = 0
+}
+
+// LastReplay returns how many value log bytes the last open replayed and how
+// long the replay took.
+func (d *BadgerDBWrapper) LastReplay() (bytes int64, took time.Duration) {
+	d.logger.mu.Lock()
+	defer d.logger.mu.Unlock()
+	for _, f := range d.logger.replayed {
+		path := filepath.Join(d.opt.ValueDir, fmt.Sprintf("%06d.vlog", f.fid))
+		if info, err := os.Stat(path); err == nil && info.Size() > int64(f.offset) {
+			bytes += info.Size() - int64(f.offset)
+		}
+	}
+	return bytes, d.logger.replayTook
+}
+
+// Compact pushes the memtables to level 0 by reopening the db (badger has no
+// public memtable flush) and then flattens the whole LSM tree into one level.
+func (d *BadgerDBWrapper) Compact(workers int) error {
+	if err := d.Reopen(); err != nil {
+		return err
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	return d.db.Flatten(workers)
+}
+
+// Size returns the on-disk size of the sst and vlog files. badger.DB.Size is
+// only refreshed once a minute, so walk the directories instead.
+func (d *BadgerDBWrapper) Size() (lsm, vlog int64, err error) {
+	sum := func(dir, ext string) (int64, error) {
+		var total int64
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && strings.HasSuffix(path, ext) {
+				total += info.Size()
+			}
+			return nil
+		})
+		return total, err
+	}
+	if lsm, err = sum(d.opt.Dir, ".sst"); err != nil {
+		return
+	}
+	vlog, err = sum(d.opt.ValueDir, ".vlog")
+	return
+}
+
 func (d *BadgerDBWrapper) Close() error {
 	return d.db.Close()
 }
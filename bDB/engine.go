@@ -0,0 +1,79 @@
+package bDB
+
+import (
+	"errors"
+	"time"
+)
+
+// ====================================
+//
+//	Storage engine interface
+//
+// ====================================
+
+// Engine is what the generic workloads run against. BadgerDBWrapper is one,
+// MemDB is an in-memory baseline without any I/O.
+type Engine interface {
+	Put(key, value string) error
+	// Get returns ErrNotFound if key has no live value.
+	Get(key string) (string, error)
+	// MultiGet looks up keys as of one point in time. values[i] is empty if
+	// keys[i] was not found.
+	MultiGet(keys []string) (values []string, found int, err error)
+	Delete(key string) error
+	NewBatch(atomic bool) Batch
+	NewIterator(opt IteratorOptions) Iterator
+	Close() error
+}
+
+// ErrNotFound is returned by Engine.Get for missing keys. BadgerDBWrapper
+// maps badger.ErrKeyNotFound to it.
+var ErrNotFound = errors.New("key not found")
+
+// Batch buffers writes until Commit. An atomic batch commits them as one
+// transaction, otherwise the engine is free to apply them piecemeal, even
+// before Commit.
+type Batch interface {
+	// Set writes key, which expires after ttl unless it is 0.
+	Set(key, value []byte, ttl time.Duration) error
+	Delete(key []byte) error
+	// Commit writes out everything pending; the batch can be used again.
+	Commit() error
+	// Cancel drops everything pending; the batch can't be used anymore.
+	Cancel()
+}
+
+// IteratorOptions mirror badger's. The prefetch fields are hints other
+// engines may ignore.
+type IteratorOptions struct {
+	Reverse        bool
+	Prefix         []byte // Valid is false past the keys with this prefix
+	PrefetchValues bool
+	PrefetchSize   int
+}
+
+// Iterator walks over the keys in order, or in reverse order if
+// IteratorOptions.Reverse is set. Whether it sees writes made after it was
+// created is up to the engine. Key and Value are only valid until Next.
+type Iterator interface {
+	Rewind()
+	// Seek moves to the first key >= key, or the last key <= key in reverse.
+	Seek(key []byte)
+	Valid() bool
+	Next()
+	Key() []byte
+	Value(f func(value []byte) error) error
+	Close()
+}
+
+// ValueLocator is implemented by iterators that can tell whether the value
+// of the current key lives in a value log, without reading it.
+type ValueLocator interface {
+	ValueInVlog() bool
+}
+
+// Engines opens the engines other than badger by name. Badger is opened
+// with the options built by the benchmark instead.
+var Engines = map[string]func(dir string) (Engine, error){
+	"memory": OpenMemDB,
+}
@@ -0,0 +1,324 @@
+package bDB
+
+import (
+	"bytes"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ====================================
+//
+//	In-memory engine
+//	(an ordered map on a skiplist, like leveldb's memtable)
+//
+// ====================================
+
+const kMemDBMaxHeight = 12
+
+type memNode struct {
+	key       []byte
+	value     []byte
+	expiresAt int64 // unix seconds, 0 if the key never expires
+	removed   bool  // unlinked by a Delete, guarded by the write lock
+	next      []*memNode
+}
+
+func (n *memNode) expired(now int64) bool {
+	return n.expiresAt != 0 && n.expiresAt <= now
+}
+
+// MemDB keeps everything in memory and forgets it on Close. Iterators don't
+// see a snapshot: they walk the live list, so they see concurrent writes.
+type MemDB struct {
+	mu     sync.RWMutex
+	head   *memNode
+	height int
+	rnd    *rand.Rand // guarded by the write lock
+}
+
+func OpenMemDB(dir string) (Engine, error) {
+	m := new(MemDB)
+	m.head = &memNode{next: make([]*memNode, kMemDBMaxHeight)}
+	m.height = 1
+	m.rnd = rand.New(rand.NewSource(0xdeadbeef))
+	return m, nil
+}
+
+func (m *MemDB) randomHeight() int {
+	// increase the height with probability 1/4, as leveldb does
+	h := 1
+	for h < kMemDBMaxHeight && m.rnd.Intn(4) == 0 {
+		h++
+	}
+	return h
+}
+
+// findGreaterOrEqual returns the first node with a key >= key. If prev is not
+// nil, prev[level] is set to the last node before it at every level.
+func (m *MemDB) findGreaterOrEqual(key []byte, prev []*memNode) *memNode {
+	x := m.head
+	level := m.height - 1
+	for {
+		next := x.next[level]
+		if next != nil && bytes.Compare(next.key, key) < 0 {
+			x = next
+			continue
+		}
+		if prev != nil {
+			prev[level] = x
+		}
+		if level == 0 {
+			return next
+		}
+		level--
+	}
+}
+
+// findLessThan returns the last node with a key < key, or nil.
+func (m *MemDB) findLessThan(key []byte) *memNode {
+	x := m.head
+	for level := m.height - 1; level >= 0; level-- {
+		for next := x.next[level]; next != nil && bytes.Compare(next.key, key) < 0; next = x.next[level] {
+			x = next
+		}
+	}
+	if x == m.head {
+		return nil
+	}
+	return x
+}
+
+// findGreaterThan returns the first node with a key > key, or nil.
+func (m *MemDB) findGreaterThan(key []byte) *memNode {
+	x := m.findGreaterOrEqual(key, nil)
+	if x != nil && bytes.Equal(x.key, key) {
+		x = x.next[0]
+	}
+	return x
+}
+
+func (m *MemDB) findLast() *memNode {
+	x := m.head
+	for level := m.height - 1; level >= 0; level-- {
+		for x.next[level] != nil {
+			x = x.next[level]
+		}
+	}
+	if x == m.head {
+		return nil
+	}
+	return x
+}
+
+// set and remove must be called with the write lock held.
+func (m *MemDB) set(key, value []byte, expiresAt int64) {
+	var prev [kMemDBMaxHeight]*memNode
+	x := m.findGreaterOrEqual(key, prev[:])
+	if x != nil && bytes.Equal(x.key, key) {
+		// readers only ever load the value under the read lock, so it can
+		// be swapped in place
+		x.value = value
+		x.expiresAt = expiresAt
+		return
+	}
+	h := m.randomHeight()
+	for ; m.height < h; m.height++ {
+		prev[m.height] = m.head
+	}
+	x = &memNode{key: key, value: value, expiresAt: expiresAt, next: make([]*memNode, h)}
+	for i := 0; i < h; i++ {
+		x.next[i] = prev[i].next[i]
+		prev[i].next[i] = x
+	}
+}
+
+func (m *MemDB) remove(key []byte) {
+	var prev [kMemDBMaxHeight]*memNode
+	x := m.findGreaterOrEqual(key, prev[:])
+	if x == nil || !bytes.Equal(x.key, key) {
+		return
+	}
+	for i := range x.next {
+		prev[i].next[i] = x.next[i]
+	}
+	// x keeps its links, but they may lead to nodes removed after it, so
+	// iterators sitting on it look up the next key instead
+	x.removed = true
+}
+
+func expiresAt(ttl time.Duration) int64 {
+	if ttl == 0 {
+		return 0
+	}
+	return time.Now().Add(ttl).Unix()
+}
+
+func (m *MemDB) Put(key, value string) error {
+	m.mu.Lock()
+	m.set([]byte(key), []byte(value), 0)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemDB) Get(key string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	x := m.findGreaterOrEqual([]byte(key), nil)
+	if x == nil || string(x.key) != key || x.expired(time.Now().Unix()) {
+		return "", ErrNotFound
+	}
+	return string(x.value), nil
+}
+
+func (m *MemDB) MultiGet(keys []string) (values []string, found int, err error) {
+	values = make([]string, len(keys))
+	now := time.Now().Unix()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for i, key := range keys {
+		x := m.findGreaterOrEqual([]byte(key), nil)
+		if x != nil && string(x.key) == key && !x.expired(now) {
+			values[i] = string(x.value)
+			found++
+		}
+	}
+	return values, found, nil
+}
+
+func (m *MemDB) Delete(key string) error {
+	m.mu.Lock()
+	m.remove([]byte(key))
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemDB) Close() error {
+	m.mu.Lock()
+	m.head = &memNode{next: make([]*memNode, kMemDBMaxHeight)}
+	m.height = 1
+	m.mu.Unlock()
+	return nil
+}
+
+// memBatch applies all its writes at once under the write lock, so it is
+// always atomic.
+type memBatch struct {
+	m       *MemDB
+	pending []memWrite
+}
+
+type memWrite struct {
+	key, value []byte
+	expiresAt  int64
+	del        bool
+}
+
+func (m *MemDB) NewBatch(atomic bool) Batch {
+	return &memBatch{m: m}
+}
+
+func (b *memBatch) Set(key, value []byte, ttl time.Duration) error {
+	k := append([]byte{}, key...)
+	v := append([]byte{}, value...)
+	b.pending = append(b.pending, memWrite{key: k, value: v, expiresAt: expiresAt(ttl)})
+	return nil
+}
+
+func (b *memBatch) Delete(key []byte) error {
+	b.pending = append(b.pending, memWrite{key: append([]byte{}, key...), del: true})
+	return nil
+}
+
+func (b *memBatch) Commit() error {
+	b.m.mu.Lock()
+	for _, w := range b.pending {
+		if w.del {
+			b.m.remove(w.key)
+		} else {
+			b.m.set(w.key, w.value, w.expiresAt)
+		}
+	}
+	b.m.mu.Unlock()
+	b.pending = b.pending[:0]
+	return nil
+}
+
+func (b *memBatch) Cancel() {
+	b.pending = nil
+}
+
+type memIterator struct {
+	m    *MemDB
+	opt  IteratorOptions
+	node *memNode
+	now  int64
+}
+
+func (m *MemDB) NewIterator(opt IteratorOptions) Iterator {
+	return &memIterator{m: m, opt: opt, now: time.Now().Unix()}
+}
+
+// skipExpired must be called with the read lock held.
+func (it *memIterator) skipExpired() {
+	for it.node != nil && it.node.expired(it.now) {
+		it.step()
+	}
+}
+
+func (it *memIterator) step() {
+	if it.opt.Reverse {
+		it.node = it.m.findLessThan(it.node.key)
+	} else if it.node.removed {
+		it.node = it.m.findGreaterThan(it.node.key)
+	} else {
+		it.node = it.node.next[0]
+	}
+}
+
+func (it *memIterator) Rewind() {
+	it.m.mu.RLock()
+	if it.opt.Reverse {
+		it.node = it.m.findLast()
+	} else {
+		it.node = it.m.head.next[0]
+	}
+	it.skipExpired()
+	it.m.mu.RUnlock()
+}
+
+func (it *memIterator) Seek(key []byte) {
+	it.m.mu.RLock()
+	it.node = it.m.findGreaterOrEqual(key, nil)
+	if it.opt.Reverse && (it.node == nil || !bytes.Equal(it.node.key, key)) {
+		it.node = it.m.findLessThan(key)
+	}
+	it.skipExpired()
+	it.m.mu.RUnlock()
+}
+
+func (it *memIterator) Valid() bool {
+	return it.node != nil && bytes.HasPrefix(it.node.key, it.opt.Prefix)
+}
+
+func (it *memIterator) Next() {
+	it.m.mu.RLock()
+	it.step()
+	it.skipExpired()
+	it.m.mu.RUnlock()
+}
+
+func (it *memIterator) Key() []byte {
+	return it.node.key
+}
+
+func (it *memIterator) Value(f func(value []byte) error) error {
+	it.m.mu.RLock()
+	v := it.node.value
+	it.m.mu.RUnlock()
+	return f(v)
+}
+
+func (it *memIterator) Close() {
+	it.node = nil
+}
@@ -0,0 +1,196 @@
+package bDB
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func openMemDB(t *testing.T, keys ...string) *MemDB {
+	t.Helper()
+	e, err := OpenMemDB("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := e.(*MemDB)
+	for _, key := range keys {
+		if err := m.Put(key, "v"+key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+func scan(it Iterator) []string {
+	var keys []string
+	for ; it.Valid(); it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+	return keys
+}
+
+func checkKeys(t *testing.T, what string, got []string, want ...string) {
+	t.Helper()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("%s: got %v, want %v", what, got, want)
+	}
+}
+
+func TestMemDBGetPutDelete(t *testing.T) {
+	m := openMemDB(t, "b", "a", "c")
+	if v, err := m.Get("a"); err != nil || v != "va" {
+		t.Errorf("Get(a) = %q, %v", v, err)
+	}
+	if err := m.Put("a", "new"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := m.Get("a"); err != nil || v != "new" {
+		t.Errorf("Get(a) after overwrite = %q, %v", v, err)
+	}
+	if err := m.Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Get("b"); err != ErrNotFound {
+		t.Errorf("Get(b) after delete: got %v, want ErrNotFound", err)
+	}
+	if _, err := m.Get("zz"); err != ErrNotFound {
+		t.Errorf("Get(zz): got %v, want ErrNotFound", err)
+	}
+	values, found, err := m.MultiGet([]string{"a", "b", "c"})
+	if err != nil || found != 2 || values[0] != "new" || values[1] != "" || values[2] != "vc" {
+		t.Errorf("MultiGet = %q, %d, %v", values, found, err)
+	}
+}
+
+func TestMemDBIterate(t *testing.T) {
+	var keys []string
+	for i := 0; i < 1000; i++ {
+		keys = append(keys, fmt.Sprintf("%04d", (i*7919)%1000))
+	}
+	m := openMemDB(t, keys...)
+
+	it := m.NewIterator(IteratorOptions{})
+	it.Rewind()
+	got := scan(it)
+	if len(got) != 1000 {
+		t.Fatalf("forward scan: got %d keys, want 1000", len(got))
+	}
+	for i, key := range got {
+		if want := fmt.Sprintf("%04d", i); key != want {
+			t.Fatalf("forward scan: key %d is %s, want %s", i, key, want)
+		}
+	}
+
+	it = m.NewIterator(IteratorOptions{Reverse: true})
+	it.Rewind()
+	got = scan(it)
+	if len(got) != 1000 || got[0] != "0999" || got[999] != "0000" {
+		t.Errorf("reverse scan: got %d keys from %s to %s", len(got), got[0], got[len(got)-1])
+	}
+
+	it = m.NewIterator(IteratorOptions{Prefix: []byte("012")})
+	it.Seek([]byte("012"))
+	checkKeys(t, "prefix scan", scan(it),
+		"0120", "0121", "0122", "0123", "0124", "0125", "0126", "0127", "0128", "0129")
+}
+
+func TestMemDBSeek(t *testing.T) {
+	m := openMemDB(t, "b", "d", "f")
+	for _, c := range []struct {
+		key      string
+		reverse  bool
+		wantKeys []string
+	}{
+		{"a", false, []string{"b", "d", "f"}},
+		{"d", false, []string{"d", "f"}},
+		{"e", false, []string{"f"}},
+		{"g", false, nil},
+		{"a", true, nil},
+		{"b", true, []string{"b"}},
+		{"c", true, []string{"b"}},
+		{"d", true, []string{"d", "b"}},
+		{"e", true, []string{"d", "b"}},
+		{"z", true, []string{"f", "d", "b"}},
+	} {
+		it := m.NewIterator(IteratorOptions{Reverse: c.reverse})
+		it.Seek([]byte(c.key))
+		checkKeys(t, fmt.Sprintf("Seek(%s) reverse=%v", c.key, c.reverse), scan(it), c.wantKeys...)
+	}
+
+	if x := m.findLessThan([]byte("b")); x != nil {
+		t.Errorf("findLessThan(b) = %s, want nil", x.key)
+	}
+	if x := m.findLessThan([]byte("e")); x == nil || string(x.key) != "d" {
+		t.Errorf("findLessThan(e) is not d")
+	}
+}
+
+func TestMemDBDeleteUnderIterator(t *testing.T) {
+	m := openMemDB(t, "a", "b", "c", "d")
+	it := m.NewIterator(IteratorOptions{})
+	it.Seek([]byte("b"))
+	if err := m.Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Delete("c"); err != nil {
+		t.Fatal(err)
+	}
+	// the iterator still sits on b, and moves on past the removed c
+	checkKeys(t, "scan over removed keys", scan(it), "b", "d")
+
+	it = m.NewIterator(IteratorOptions{Reverse: true})
+	it.Seek([]byte("d"))
+	if err := m.Delete("d"); err != nil {
+		t.Fatal(err)
+	}
+	checkKeys(t, "reverse scan over removed key", scan(it), "d", "a")
+}
+
+func TestMemDBBatch(t *testing.T) {
+	m := openMemDB(t, "a", "b")
+	b := m.NewBatch(true)
+	key := []byte("c")
+	if err := b.Set(key, []byte("vc"), 0); err != nil {
+		t.Fatal(err)
+	}
+	// the batch keeps its own copy
+	key[0] = 'x'
+	if err := b.Delete([]byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Get("c"); err != ErrNotFound {
+		t.Errorf("Get(c) before Commit: got %v, want ErrNotFound", err)
+	}
+	if err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	it := m.NewIterator(IteratorOptions{})
+	it.Rewind()
+	checkKeys(t, "scan after Commit", scan(it), "b", "c")
+}
+
+func TestMemDBExpiry(t *testing.T) {
+	m := openMemDB(t, "a", "c")
+	b := m.NewBatch(false)
+	if err := b.Set([]byte("b"), []byte("vb"), -time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Set([]byte("d"), []byte("vd"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Get("b"); err != ErrNotFound {
+		t.Errorf("Get(b) of an expired key: got %v, want ErrNotFound", err)
+	}
+	if v, err := m.Get("d"); err != nil || v != "vd" {
+		t.Errorf("Get(d) = %q, %v", v, err)
+	}
+	it := m.NewIterator(IteratorOptions{})
+	it.Rewind()
+	checkKeys(t, "forward scan", scan(it), "a", "c", "d")
+	it = m.NewIterator(IteratorOptions{Reverse: true})
+	it.Seek([]byte("b"))
+	checkKeys(t, "reverse scan", scan(it), "a")
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"badgerBench/bDB"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ======================================
+//
+//	Backup and restore
+//
+// ======================================
+
+// Max pending writes used by DB.Load
+const kLoadMaxPendingWrites = 256
+
+func (bm *Benchmark) FullBackup(thread *ThreadState) {
+	bm.backupFiles = nil
+	bm.backupSince = 0
+	bm.doBackup(thread)
+}
+
+func (bm *Benchmark) IncrementalBackup(thread *ThreadState) {
+	if len(bm.backupFiles) == 0 {
+		fmt.Fprintf(os.Stderr, "backupinc needs a full backup first\n")
+		os.Exit(1)
+	}
+	bm.doBackup(thread)
+}
+
+func (bm *Benchmark) doBackup(thread *ThreadState) {
+	if err := os.MkdirAll(FLAGS_backup_dir, 0777); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create backup dir: %s\n", err.Error())
+		os.Exit(1)
+	}
+	path := filepath.Join(FLAGS_backup_dir, fmt.Sprintf("backup-%d.bak", len(bm.backupFiles)))
+	since := bm.backupSince
+	start := time.Now()
+	next, err := bm.db.Backup(path, since)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to backup: %s\n", err.Error())
+		os.Exit(1)
+	}
+	elapsed := time.Since(start)
+	thread.stats.FinishedSingleOp()
+	thread.stats.Stop()
+	// what a restore of the backups so far should give back; nothing writes
+	// to the db while a backup runs
+	keys, err := bm.db.KeyCount()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to count keys: %s\n", err.Error())
+		os.Exit(1)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to stat backup: %s\n", err.Error())
+		os.Exit(1)
+	}
+	bm.backupFiles = append(bm.backupFiles, path)
+	bm.backupSince = next
+	bm.backupKeys = keys
+	thread.stats.AddBytes(info.Size())
+	msg := fmt.Sprintf("(%.3f s, %.1f MB backup, since version %d)",
+		elapsed.Seconds(), float64(info.Size())/1048576.0, since)
+	thread.stats.AddMsg(msg)
+}
+
+// OpenRestoreDB opens an empty db to restore the backups into. It is called
+// by Run before the restore benchmark, so that opening it is not timed.
+func (bm *Benchmark) OpenRestoreDB() {
+	if len(bm.backupFiles) == 0 {
+		fmt.Fprintf(os.Stderr, "restore needs a backup first\n")
+		os.Exit(1)
+	}
+	if err := os.RemoveAll(FLAGS_restore_dir); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to drop restore dir: %s\n", err.Error())
+		os.Exit(1)
+	}
+	opt := CreateDBOption()
+	opt.Dir = FLAGS_restore_dir
+	opt.ValueDir = FLAGS_restore_dir
+	bm.restoreDB = bDB.MakeDB()
+	if err := bm.restoreDB.Open(opt); err != nil {
+		fmt.Fprintf(os.Stderr, "err occurs when open restore db: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func (bm *Benchmark) Restore(thread *ThreadState) {
+	restored := bm.restoreDB
+	var bytes int64 = 0
+	start := time.Now()
+	for _, path := range bm.backupFiles {
+		if err := restored.Load(path, kLoadMaxPendingWrites); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to restore %s: %s\n", path, err.Error())
+			os.Exit(1)
+		}
+		if info, err := os.Stat(path); err == nil {
+			bytes += info.Size()
+		}
+	}
+	elapsed := time.Since(start)
+	thread.stats.FinishedSingleOp()
+	thread.stats.AddBytes(bytes)
+	thread.stats.Stop()
+
+	got, err := restored.KeyCount()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to count restored keys: %s\n", err.Error())
+		os.Exit(1)
+	}
+	restored.Close()
+	bm.restoreDB = nil
+	expected := bm.backupKeys
+	verified := "ok"
+	if got != expected {
+		verified = "MISMATCH"
+	}
+	msg := fmt.Sprintf("(%.3f s, %d backup files, %d of %d keys restored, %s)",
+		elapsed.Seconds(), len(bm.backupFiles), got, expected, verified)
+	thread.stats.AddMsg(msg)
+}
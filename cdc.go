@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/badger"
+)
+
+// ======================================
+//
+//	Change data capture (DB.Subscribe)
+//
+// ======================================
+
+// Written under --subscribe_prefix to check that the subscribers are
+// registered before the writers start.
+const kSubscribeSentinel = "~subscribe-sentinel"
+
+// Subscriber owns one DB.Subscribe call. Every value published by
+// PublishRandom starts with its write time in unix nanoseconds.
+type Subscriber struct {
+	hist      Histrogram // commit-to-notification latency, in micros
+	received  int64
+	first     int64 // unix nanos of the first and last notification
+	last      int64
+	ready     chan struct{}
+	readyOnce sync.Once
+	cancel    context.CancelFunc
+	done      chan error
+}
+
+func (sub *Subscriber) callback(list *badger.KVList) error {
+	now := time.Now().UnixNano()
+	for _, kv := range list.Kv {
+		if strings.HasSuffix(string(kv.Key), kSubscribeSentinel) {
+			sub.readyOnce.Do(func() { close(sub.ready) })
+			continue
+		}
+		if len(kv.Value) < 8 {
+			continue
+		}
+		written := int64(binary.BigEndian.Uint64(kv.Value))
+		sub.hist.Add(float64(now-written) / 1e3)
+		if sub.first == 0 {
+			sub.first = now
+		}
+		sub.last = now
+		atomic.AddInt64(&sub.received, 1)
+	}
+	return nil
+}
+
+// StartSubscribers subscribes --subscribers times to --subscribe_prefix and
+// waits until every subscriber is receiving updates.
+func (bm *Benchmark) StartSubscribers() {
+	bm.subscribers = make([]*Subscriber, FLAGS_subscribers)
+	atomic.StoreInt64(&bm.published, 0)
+	for i := range bm.subscribers {
+		sub := new(Subscriber)
+		sub.hist.Clear()
+		sub.ready = make(chan struct{})
+		sub.done = make(chan error, 1)
+		var ctx context.Context
+		ctx, sub.cancel = context.WithCancel(context.Background())
+		go func() {
+			sub.done <- bm.db.Subscribe(ctx, sub.callback, []byte(FLAGS_subscribe_prefix))
+		}()
+		bm.subscribers[i] = sub
+	}
+
+	// DB.Subscribe registers asynchronously, keep writing the sentinel until
+	// everyone has seen it
+	sentinel := FLAGS_subscribe_prefix + kSubscribeSentinel
+	for _, sub := range bm.subscribers {
+		for registered := false; !registered; {
+			if err := bm.db.Put(sentinel, ""); err != nil {
+				fmt.Fprintf(os.Stderr, "put errror: %s\n", err.Error())
+				os.Exit(1)
+			}
+			select {
+			case <-sub.ready:
+				registered = true
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+	if err := bm.db.Delete(sentinel); err != nil {
+		fmt.Fprintf(os.Stderr, "delete error: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+// commitsEachWrite tells whether PublishRandom commits every write on its
+// own, so that the time stamped into a value is its commit time.
+func commitsEachWrite() bool {
+	switch WriteMode(writeModeBatchN) {
+	case writeModeOp:
+		return true
+	case writeModeBatchN, writeModeTxn:
+		return FLAGS_batch_size <= 1
+	}
+	return false
+}
+
+// PublishRandom is fillrandom with the write time stamped into each value.
+// Run makes sure each value is committed on its own, see commitsEachWrite.
+func (bm *Benchmark) PublishRandom(thread *ThreadState) {
+	var bytes int64 = 0
+	rnd := thread.NewRand("values")
+	w := bm.NewBatchWriter(WriteMode(writeModeBatchN))
+	thread.stats.AddMsg(w.String())
+	values := NewValueGenerator(rnd, bm.valueSize)
+	for i := 0; i < bm.num; i++ {
+		key := GenKey(thread.NextKey(FLAGS_num))
+		// the batch keeps the slice until it is committed, and the stamp
+		// needs at least 8 bytes
+		value := values.Next()
+		stamped := make([]byte, len(value))
+		if len(stamped) < 8 {
+			stamped = make([]byte, 8)
+		}
+		copy(stamped, value)
+		binary.BigEndian.PutUint64(stamped, uint64(time.Now().UnixNano()))
+		if err := w.Set([]byte(key), stamped); err != nil {
+			fmt.Fprintf(os.Stderr, "put errror: %s\n", err.Error())
+			os.Exit(1)
+		}
+		if strings.HasPrefix(key, FLAGS_subscribe_prefix) {
+			atomic.AddInt64(&bm.published, 1)
+		}
+		bytes += int64(len(stamped)) + int64(len(key))
+		thread.stats.FinishedSingleOp()
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "put errror: %s\n", err.Error())
+		os.Exit(1)
+	}
+	thread.stats.AddBytes(bytes)
+}
+
+// CollectNotifications waits for the writers, gives the subscribers up to
+// --subscribe_drain to catch up and then stops them and merges their stats.
+func (bm *Benchmark) CollectNotifications(thread *ThreadState) {
+	for !thread.shared.ForegroundDone() {
+		time.Sleep(time.Millisecond)
+	}
+	published := atomic.LoadInt64(&bm.published)
+	deadline := time.Now().Add(FLAGS_subscribe_drain)
+	for _, sub := range bm.subscribers {
+		for atomic.LoadInt64(&sub.received) < published && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// updates/sec is taken over the time notifications were arriving, from
+	// the first to the last one of any subscriber
+	var received, first, last int64
+	for _, sub := range bm.subscribers {
+		sub.cancel()
+		if err := <-sub.done; err != nil && err != context.Canceled {
+			fmt.Fprintf(os.Stderr, "subscribe error: %s\n", err.Error())
+			os.Exit(1)
+		}
+		thread.stats.opHist("notify").Merge(&sub.hist)
+		received += sub.received
+		if sub.first != 0 && (first == 0 || sub.first < first) {
+			first = sub.first
+		}
+		if sub.last > last {
+			last = sub.last
+		}
+	}
+	bm.subscribers = nil
+	thread.stats.AddOps(int(received))
+	msg := fmt.Sprintf("(%d subscribers, %d of %d updates received)",
+		FLAGS_subscribers, received, published*int64(FLAGS_subscribers))
+	thread.stats.AddMsg(msg)
+	thread.stats.AddCounter("received", received)
+	thread.stats.AddCounter("window", last-first)
+	thread.stats.SetSummary(func(s *Stats) string {
+		updatesPerSec := 0.
+		if window := float64(s.counters["window"]) * 1e-9; window > 0 {
+			updatesPerSec = float64(s.counters["received"]) / window
+		}
+		return fmt.Sprintf("(%.0f updates/sec)", updatesPerSec)
+	})
+}
@@ -3,12 +3,16 @@ package main
 import (
 	"badgerBench/bDB"
 	"bufio"
+	"encoding/binary"
 	"flag"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"math/rand"
 	"os"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -24,12 +28,63 @@ import (
 //	   overwrite     -- overwrite N values in random key order in async mode
 //	   fillsync      -- write N/100 values in random key order in sync mode
 //	   fill100K      -- write N/1000 100K values in random order in async mode
+//	   deleteseq     -- delete N keys in sequential order through a WriteBatch
+//	   deleterandom  -- delete N keys in random order through a WriteBatch
+//	   deleteseqtxn  -- delete N keys in sequential order, one txn per key
+//	   deleterandomtxn -- delete N keys in random order, one txn per key
 //	   readseq       -- read N times sequentially
 //	   readreverse   -- read N times in reverse order
+//	   readkeys      -- iterate N keys sequentially without fetching values
+//	   readkeysreverse -- iterate N keys in reverse order without fetching values
 //	   readrandom    -- read N times in random order
+//	   readdeleted   -- read N times in random order, counting tombstone hits
+//	   multireadrandom -- read N random keys in batches of --multiget_size, one
+//	                    read txn per batch (micros/op is per batch)
+//	   fillprefix    -- write --keys_per_prefix keys under each of --prefixes
+//	                    prefixes, keys look like <prefix>/<id>
+//	   prefixscan    -- scan every key under a random prefix, about N rows total
+//	   stream        -- scan the whole DB (or --stream_prefix) with badger's Stream
+//	                    framework using --stream_num_go goroutines
 //	   readhot       -- read N times in random order from 1% section of DB
+//	                    (the section size is controlled by --hot_ratio)
+//	   readwhilewriting -- --threads readrandom threads plus one background
+//	                    writer; the writer is reported separately as <name>.bg
+//	   subscribe     -- fillrandom while --subscribers DB.Subscribe subscribers watch
+//	                    --subscribe_prefix; the subscribers are reported as <name>.bg
+//	   seekrandom    -- N times seek to a random key and call Next() --seek_nexts times
+//	   seekrandomreverse -- same as seekrandom, iterating in reverse order
+//	   txnrmw        -- N get-then-set transactions over --txn_hot_keys keys,
+//	                    retrying on conflicts
+//	   mergerandom   -- N merges into random counters of --merge_keys merge operators
+//	   countertxn    -- N increments of random counters in read-modify-write txns
+//	   mergeverify   -- check the values of the counters above
+//	   fillttl       -- fillseq with every key expiring after --ttl
+//	   fillttlrandom -- fillrandom with every key expiring after --ttl
+//	   waitttl       -- sleep until the keys of the last fillttl have expired
+//	   readttl       -- read N times in random order, counting expired keys
+//	   sequence      -- N Next() calls per thread on one shared badger Sequence
+//	   sequencethread -- N Next() calls per thread on a Sequence of its own
+//	   fillversions  -- (--managed) write --versions versions of N keys, version v
+//	                    committed at timestamp v
+//	   readold       -- (--managed) read N times in random order at a random
+//	                    timestamp older than the latest one
+//	   readlatest    -- (--managed) read N times in random order at the latest timestamp
+//	   ycsba .. ycsbf -- YCSB core workloads A-F, --reads ops over the first
+//	                    --num keys (load them with fillseq or fillrandom first)
 //	Meta operations:
 //	   compact     -- Compact the entire DB
+//	                  (flush memtables, then flatten the LSM tree into one level)
+//	   reclaim     -- compact, then run value log GC until it has nothing to do,
+//	                  reporting how much space came back
+//	   sequencereopen -- count the IDs a Sequence loses across a reopen, with
+//	                  and without Release
+//	   open        -- close the db cleanly and time reopening it
+//	   opencrash   -- kill a child process writing to the db after --crash_after,
+//	                  then time reopening it without and with Truncate
+//	   backup      -- write a full backup to --backup_dir
+//	   backupinc   -- write an incremental backup of what changed since the last one
+//	   restore     -- load the full backup and its incremental ones into a fresh
+//	                  db at --restore_dir and verify the key count
 var FLAGS_benchmarks []string = []string{
 	"fillrandom",
 	"fillsync",
@@ -73,6 +128,14 @@ var FLAGS_reads int = -1
 // Number of concurrent threads to run
 var FLAGS_threads int = 1
 
+// Number of entries per WriteBatch or transaction in the batchn and txn
+// write modes
+var FLAGS_batch_size int = 1
+
+// How writes are applied: batch, batchn, txn or op. If empty, each
+// benchmark uses its own default (see writer.go).
+var FLAGS_write_mode string = ""
+
 // Size of each value
 var FLAGS_value_size int = 100
 
@@ -92,6 +155,20 @@ var FLAGS_vlog_max_entries uint32 = 0
 // key size
 var FLAGS_key_size int = 16
 
+// Distribution of the value sizes: fixed (always --value_size), uniform,
+// normal or zipf, within --value_size_min and --value_size_max
+var FLAGS_value_size_dist string = valueDistFixed
+
+// Bounds of the value sizes. If negative, half and twice --value_size.
+var FLAGS_value_size_min int = -1
+var FLAGS_value_size_max int = -1
+
+// Values compress to about this fraction of their size
+var FLAGS_compression_ratio float64 = 0.5
+
+// How keys are encoded: decimal, binary, random or prefix (see GenKey)
+var FLAGS_key_format string = keyFormatDecimal
+
 // db name
 var FLAGS_db string = "/tmp/BadgerBench"
 
@@ -101,9 +178,60 @@ var FLAGS_num_level0 = 0
 // Number of stalled tables at level0
 var FLAGS_num_level0_stall = 0
 
+// Distribution of the keys picked by the random benchmarks: uniform,
+// zipfian, latest, hotspot or exponential. If empty, they are uniform and
+// the ycsb workloads use their own distribution.
+var FLAGS_key_dist string = ""
+
+// Skew of the zipfian and latest distributions, in (0, 1)
+var FLAGS_zipf_theta float64 = kZipfianConstant
+
+// The hotspot distribution sends this fraction of the ops...
+var FLAGS_hotspot_ops_fraction float64 = 0.8
+
+// ...to this fraction of the keys
+var FLAGS_hotspot_set_fraction float64 = 0.2
+
+// The exponential distribution puts this percentile of the ops...
+var FLAGS_exp_percentile float64 = 95
+
+// ...in this fraction of the keys
+var FLAGS_exp_fraction float64 = 0.8571428571
+
+// Seed of every random stream, see StreamSeed
+var FLAGS_seed int64 = 301
+
+// Storage engine: badger, or one of bDB.Engines (memory)
+var FLAGS_engine string = kBadgerEngine
+
+// Loading modes of the LSM tables (fileio, mmap or ram) and of the value
+// log (fileio or mmap). With more than one mode listed, the benchmarks run
+// once per combination and a summary compares them.
+var FLAGS_table_loading_mode []string = []string{"mmap"}
+var FLAGS_vlog_loading_mode []string = []string{"mmap"}
+
 // KV pairs to prefetch while iterating.
 var FLAGS_read_prefetch_size int = -1
 
+// Open the db with badger.OpenManaged. Only the benchmarks in
+// managedBenchmarks can run in managed mode.
+var FLAGS_managed = false
+
+// Number of versions written per key by fillversions
+var FLAGS_versions int = 10
+
+// Max versions kept per key once they are older than --discard_ts
+var FLAGS_num_versions_to_keep int = 1
+
+// Timestamp passed to SetDiscardTs after fillversions, 0 keeps every version
+var FLAGS_discard_ts uint64 = 0
+
+// How long the opencrash child writes before it is killed
+var FLAGS_crash_after time.Duration = 2 * time.Second
+
+// Internal: run as the child process of opencrash
+var FLAGS_crash_child = false
+
 // If true, do not destroy the existing database.  If you set this
 // flag and also specify a benchmark that wants a fresh database, that
 // benchmark will fail.
@@ -111,6 +239,67 @@ var FLAGS_use_existing_db = false
 
 var FLAGS_histogram = false
 
+// Number of Next() calls after each Seek() in seekrandom
+var FLAGS_seek_nexts int = 10
+
+// Number of keys contended for by txnrmw
+var FLAGS_txn_hot_keys int = 100
+
+// Times a conflicting txnrmw transaction is retried before it is aborted
+var FLAGS_txn_retries int = 10
+
+// Number of counters updated by mergerandom and countertxn
+var FLAGS_merge_keys int = 1000
+
+// Merge function of mergerandom: add (uint64 add) or append
+var FLAGS_merge_func string = "add"
+
+// How often the merge operators compact their operands
+var FLAGS_merge_interval time.Duration = 100 * time.Millisecond
+
+// TTL of the keys written by fillttl and fillttlrandom
+var FLAGS_ttl time.Duration = 10 * time.Second
+
+// Discard ratio passed to RunValueLogGC by reclaim
+var FLAGS_gc_discard_ratio float64 = 0.5
+
+// Number of keys looked up per transaction by multireadrandom
+var FLAGS_multiget_size int = 10
+
+// Number of prefixes written by fillprefix. If 0, --num / --keys_per_prefix.
+var FLAGS_prefixes int = 0
+
+// Number of keys under each prefix written by fillprefix
+var FLAGS_keys_per_prefix int = 100
+
+// Number of goroutines iterating key ranges in the stream benchmark
+var FLAGS_stream_num_go int = 16
+
+// Only keys with this prefix are scanned by the stream benchmark
+var FLAGS_stream_prefix string = ""
+
+// Directory holding the files written by backup and backupinc
+// (default <db>.backup)
+var FLAGS_backup_dir string = ""
+
+// Directory restore loads the backups into (default <db>.restore)
+var FLAGS_restore_dir string = ""
+
+// Number of DB.Subscribe subscribers of the subscribe benchmark
+var FLAGS_subscribers int = 1
+
+// Key prefix watched by the subscribers
+var FLAGS_subscribe_prefix string = ""
+
+// How long subscribers may lag behind after the writers finish
+var FLAGS_subscribe_drain time.Duration = 5 * time.Second
+
+// Number of integers leased at a time by the sequence benchmarks
+var FLAGS_seq_bandwidth uint64 = 1000
+
+// Fraction of the key space that readhot draws its keys from
+var FLAGS_hot_ratio float64 = 0.01
+
 func PrintEnv() {
 	fmt.Fprintf(os.Stderr, "BadgerDB     v4.2.0\n")
 	now := time.Now()
@@ -141,25 +330,37 @@ func PrintEnv() {
 }
 
 type SharedState struct {
-	mu    sync.Mutex
-	cv    *sync.Cond
-	total int
-
-	numInitialized int
-	numDone        int
-	start          bool
+	mu         sync.Mutex
+	cv         *sync.Cond
+	total      int // number of foreground threads
+	background int // number of background threads
+
+	numInitialized    int
+	numDone           int
+	numBackgroundDone int
+	start             bool
 }
 
 func MakeSharedState(total int) *SharedState {
 	stat := new(SharedState)
 	stat.total = total
 	stat.cv = sync.NewCond(&stat.mu)
+	stat.background = 0
 	stat.numInitialized = 0
 	stat.numDone = 0
+	stat.numBackgroundDone = 0
 	stat.start = false
 	return stat
 }
 
+// ForegroundDone reports whether every foreground thread has finished.
+// Background threads poll it to know when to stop.
+func (s *SharedState) ForegroundDone() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.numDone >= s.total
+}
+
 type Stats struct {
 	start        float64
 	finish       float64
@@ -169,16 +370,23 @@ type Stats struct {
 	bytes        int64
 	lastOPFinish float64
 	hist         Histrogram
+	opHists      map[string]*Histrogram // per op type, see FinishedTypedOp
+	counters     map[string]int64       // summed across threads on Merge
+	summary      func(*Stats) string    // formats counters after Merge
 	msg          string
+	stopped      bool
 }
 
 func (s *Stats) Start() {
 	s.nextReport = 100
 	s.hist.Clear()
+	s.opHists = make(map[string]*Histrogram)
+	s.counters = make(map[string]int64)
 	s.done = 0
 	s.bytes = 0
 	s.seconds = 0
 	s.msg = ""
+	s.stopped = false
 	now := time.Now().UnixMicro()
 	s.lastOPFinish = float64(now)
 	s.start = s.lastOPFinish
@@ -190,18 +398,34 @@ func (s *Stats) Merge(other *Stats) {
 	s.bytes += other.bytes
 	s.seconds += other.seconds
 	s.hist.Merge(&other.hist)
+	for op, h := range other.opHists {
+		s.opHist(op).Merge(h)
+	}
 	if other.start < s.start {
 		s.start = other.start
 	}
 	if other.finish > s.finish {
 		s.finish = other.finish
 	}
+	for name, n := range other.counters {
+		s.counters[name] += n
+	}
+	if s.summary == nil {
+		s.summary = other.summary
+	}
 	if s.msg == "" {
 		s.msg = other.msg
 	}
 }
 
+// Stop ends the timed part of a benchmark. A method can call it itself before
+// doing work which should not be measured; the call after it returns is then
+// a no-op.
 func (s *Stats) Stop() {
+	if s.stopped {
+		return
+	}
+	s.stopped = true
 	s.finish = float64(time.Now().UnixMicro())
 	s.seconds = (s.finish - s.start) * 1e-6
 }
@@ -225,10 +449,35 @@ func (s *Stats) AddMsg(msg string) {
 	AppendWithSpace(&s.msg, msg)
 }
 
+func (s *Stats) AddCounter(name string, n int64) {
+	s.counters[name] += n
+}
+
+// SetSummary registers a function that turns the merged counters of all
+// threads into the report message.
+func (s *Stats) SetSummary(f func(*Stats) string) {
+	s.summary = f
+}
+
+// AddOps counts n ops finished outside of the goroutine owning the stats.
+func (s *Stats) AddOps(n int) {
+	s.done += n
+}
+
 func (s *Stats) AddBytes(n int64) {
 	s.bytes += n
 }
 
+func (s *Stats) opHist(op string) *Histrogram {
+	h, ok := s.opHists[op]
+	if !ok {
+		h = new(Histrogram)
+		h.Clear()
+		s.opHists[op] = h
+	}
+	return h
+}
+
 func (s *Stats) FinishedSingleOp() {
 	if FLAGS_histogram {
 		now := float64(time.Now().UnixMicro())
@@ -236,6 +485,23 @@ func (s *Stats) FinishedSingleOp() {
 		s.hist.Add(dura)
 		s.lastOPFinish = now
 	}
+	s.countOp()
+}
+
+// FinishedTypedOp is FinishedSingleOp for mixed workloads. The latency is
+// always recorded in a histogram of its own for the given op type as well.
+func (s *Stats) FinishedTypedOp(op string) {
+	now := float64(time.Now().UnixMicro())
+	dura := now - s.lastOPFinish
+	if FLAGS_histogram {
+		s.hist.Add(dura)
+	}
+	s.opHist(op).Add(dura)
+	s.lastOPFinish = now
+	s.countOp()
+}
+
+func (s *Stats) countOp() {
 	s.done++
 	if s.done >= s.nextReport {
 		if s.nextReport < 1000 {
@@ -258,6 +524,17 @@ func (s *Stats) FinishedSingleOp() {
 	}
 }
 
+func (s *Stats) MicrosPerOp() float64 {
+	return s.seconds * 1e6 / float64(s.done)
+}
+
+func (s *Stats) OpsPerSec() float64 {
+	if elapsed := (s.finish - s.start) * 1e-6; elapsed > 0 {
+		return float64(s.done) / elapsed
+	}
+	return 0
+}
+
 func (s *Stats) Report(name string) {
 	if s.done < 1 {
 		s.done = 1
@@ -270,9 +547,12 @@ func (s *Stats) Report(name string) {
 	}
 
 	AppendWithSpace(&extra, s.msg)
+	if s.summary != nil {
+		AppendWithSpace(&extra, s.summary(s))
+	}
 
 	fmt.Fprintf(os.Stdout, "%-12s : %11.3f micros/op;%s%s\n",
-		name, s.seconds*1e6/float64(s.done), func() string {
+		name, s.MicrosPerOp(), func() string {
 			if extra != "" {
 				return " "
 			}
@@ -282,6 +562,19 @@ func (s *Stats) Report(name string) {
 		fmt.Fprintf(os.Stdout, "Microseconds per op:\n%s\n",
 			s.hist.ToString())
 	}
+	ops := make([]string, 0, len(s.opHists))
+	for op := range s.opHists {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	for _, op := range ops {
+		h := s.opHists[op]
+		fmt.Fprintf(os.Stdout, "  %-10s : %11.3f micros/op; %.0f ops, p50 %.3f p99 %.3f\n",
+			op, h.Average(), h.num, h.Median(), h.Percentile(99))
+		if FLAGS_histogram {
+			fmt.Fprintf(os.Stdout, "%s\n", h.ToString())
+		}
+	}
 	FFlush(os.Stdout)
 }
 
@@ -293,21 +586,65 @@ func MakeStat() Stats {
 
 // Per-thread state for concurrent executions of the same benchmark.
 type ThreadState struct {
-	tid    int        // 0..n-1 when running in n threads
-	rd     *rand.Rand // Has different seeds for different threads
-	stats  Stats
-	shared *SharedState
+	tid       int        // 0..n-1 when running in n threads
+	benchmark string     // name the thread's random streams are derived from
+	rd        *rand.Rand // Has different seeds for different threads
+	keys      KeyGenerator
+	stats     Stats
+	shared    *SharedState
 }
 
-func MakeThreadState(tid int, seed int64) *ThreadState {
+func MakeThreadState(tid int, benchmark string) *ThreadState {
 	ts := new(ThreadState)
-	ts.rd = rand.New(rand.NewSource(seed))
+	ts.benchmark = benchmark
+	ts.rd = rand.New(rand.NewSource(StreamSeed(benchmark, tid, "")))
+	dist := keyDistUniform
+	if w, ok := ycsbWorkloads[benchmark]; ok {
+		dist = w.dist
+	}
+	ts.keys = MakeKeyGenerator(KeyDist(dist), int64(KeySpace(benchmark)))
 	ts.stats = MakeStat()
 	ts.tid = tid
 	ts.shared = nil
 	return ts
 }
 
+// StreamSeed derives the seed of a random stream from --seed, the benchmark
+// name and the thread id, so that a thread draws the same numbers whatever
+// ran before it. rd is the stream named "".
+func StreamSeed(benchmark string, tid int, stream string) int64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d/%s/%d/%s", FLAGS_seed, benchmark, tid, stream)
+	return int64(h.Sum64())
+}
+
+// NewRand returns another random stream of the thread, so that e.g. the
+// values don't shift the keys drawn from rd.
+func (thread *ThreadState) NewRand(stream string) *rand.Rand {
+	return rand.New(rand.NewSource(StreamSeed(thread.benchmark, thread.tid, stream)))
+}
+
+// KeySpace returns the number of keys benchmark draws its keys from.
+func KeySpace(benchmark string) int {
+	switch benchmark {
+	case "txnrmw":
+		return FLAGS_txn_hot_keys
+	case "mergerandom", "countertxn":
+		return FLAGS_merge_keys
+	case "prefixscan":
+		return NumPrefixes()
+	case "readhot":
+		return HotRange()
+	}
+	return FLAGS_num
+}
+
+// NextKey draws a key index in [0, items) following --key_dist, or the
+// distribution of the ycsb workload.
+func (thread *ThreadState) NextKey(items int) int {
+	return int(thread.keys.Next(thread.rd, int64(items)))
+}
+
 // ============================================
 //
 //	Helper for quickly generation random data
@@ -316,13 +653,112 @@ func MakeThreadState(tid int, seed int64) *ThreadState {
 func RandomString(rnd *rand.Rand, len int) string {
 	bytes := make([]byte, len)
 	for i := 0; i < len; i++ {
-		bytes[i] = byte(' ' + rand.Int31n(95))
+		bytes[i] = byte(' ' + rnd.Int31n(95))
 	}
 	return string(bytes)
 }
 
+// key formats
+const (
+	keyFormatDecimal = "decimal" // zero-padded decimal
+	keyFormatBinary  = "binary"  // big-endian uint64, zero-padded at the end
+	keyFormatRandom  = "random"  // random-looking bytes, unique per key
+	keyFormatPrefix  = "prefix"  // a long prefix shared by all keys, then decimal digits
+)
+
+var keyFormats = map[string]bool{
+	keyFormatDecimal: true,
+	keyFormatBinary:  true,
+	keyFormatRandom:  true,
+	keyFormatPrefix:  true,
+}
+
+// Number of decimal digits after the shared prefix of the prefix format
+const kKeySuffixDigits = 16
+
+// Shared prefix of the prefix format, set up by SetupKeyFormat
+var keyPrefix string
+
+// SetupKeyFormat checks --key_size against --key_format and --num.
+func SetupKeyFormat() error {
+	if !keyFormats[FLAGS_key_format] {
+		return fmt.Errorf("unknown key_format %q", FLAGS_key_format)
+	}
+	minSize := 1
+	switch FLAGS_key_format {
+	case keyFormatDecimal:
+		minSize = len(strconv.Itoa(FLAGS_num))
+	case keyFormatBinary, keyFormatRandom:
+		minSize = 8
+	case keyFormatPrefix:
+		minSize = kKeySuffixDigits + 1
+	}
+	if FLAGS_key_size < minSize {
+		return fmt.Errorf("key_size %d is too small for %s keys, needs at least %d",
+			FLAGS_key_size, FLAGS_key_format, minSize)
+	}
+	if FLAGS_key_format == keyFormatPrefix {
+		n := FLAGS_key_size - kKeySuffixDigits
+		keyPrefix = strings.Repeat("tenant/table/index/", n/19+1)[:n]
+	}
+	return nil
+}
+
+// mix64 is the splitmix64 finalizer. It is a bijection, so random keys
+// never collide.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// GenKey returns the key of index k, --key_size bytes long in every format.
+// The decimal, binary and prefix formats sort like k.
 func GenKey(k int) string {
-	return fmt.Sprintf("%016d", k)
+	switch FLAGS_key_format {
+	case keyFormatBinary:
+		buf := make([]byte, FLAGS_key_size)
+		binary.BigEndian.PutUint64(buf, uint64(k))
+		return string(buf)
+	case keyFormatRandom:
+		buf := make([]byte, FLAGS_key_size+7)
+		for i := 0; i < FLAGS_key_size; i += 8 {
+			binary.BigEndian.PutUint64(buf[i:], mix64(uint64(k)+uint64(i)<<56))
+		}
+		return string(buf[:FLAGS_key_size])
+	case keyFormatPrefix:
+		return keyPrefix + fmt.Sprintf("%0*d", kKeySuffixDigits, k)
+	}
+	return fmt.Sprintf("%0*d", FLAGS_key_size, k)
+}
+
+// KeySize is the length of the keys made by GenKey.
+func KeySize() int {
+	return len(GenKey(FLAGS_num))
+}
+
+// Prefixed keys model tables laid out as <prefix>/<id>.
+func GenPrefix(p int) string {
+	return fmt.Sprintf("%08d/", p)
+}
+
+func GenPrefixKey(p, id int) string {
+	return GenPrefix(p) + fmt.Sprintf("%016d", id)
+}
+
+// NumPrefixes is --prefixes, or enough prefixes to hold --num keys.
+func NumPrefixes() int {
+	if FLAGS_prefixes > 0 {
+		return FLAGS_prefixes
+	}
+	n := FLAGS_num / FLAGS_keys_per_prefix
+	if n < 1 {
+		n = 1
+	}
+	return n
 }
 
 // ======================================
@@ -330,28 +766,79 @@ func GenKey(k int) string {
 // ======================================
 
 type ThreadArg struct {
-	bm     *Benchmark
-	shared *SharedState
-	thread *ThreadState
-	method func(*Benchmark, *ThreadState)
+	bm         *Benchmark
+	shared     *SharedState
+	thread     *ThreadState
+	method     func(*Benchmark, *ThreadState)
+	background bool
 }
 
 type Benchmark struct {
-	db                *bDB.BadgerDBWrapper
-	num               int // total num of entries
-	valueSize         int
-	entriesPerBatch   int
-	reads             int
-	totalThreadsCount int
+	engine          bDB.Engine           // what the generic workloads run against
+	db              *bDB.BadgerDBWrapper // nil unless --engine is badger
+	num             int                  // total num of entries
+	valueSize       int
+	entriesPerBatch int
+	reads           int
+	inserted        int64 // keys appended past FLAGS_num by ycsb inserts
+
+	mergeOps    []*badger.MergeOperator // one per merge key, see merge.go
+	mergeAdds   []int64                 // merges applied to each merge key
+	counterAdds []int64                 // increments applied to each txn counter
+
+	ttlDeadline time.Time // when the keys written by fillttl expire
+
+	backupFiles []string // full backup followed by incremental ones
+	backupSince uint64   // since version of the next incremental backup
+	backupKeys  int      // keys in the db when the last backup was taken
+
+	restoreDB *bDB.BadgerDBWrapper // opened by OpenRestoreDB
+
+	subscribers []*Subscriber // see cdc.go
+	published   int64         // writes under --subscribe_prefix
+
+	sequence *badger.Sequence // shared by the threads of the sequence benchmark
+
+	latestTs uint64 // newest commit timestamp written by fillversions
+
+	results []benchResult // one per reported benchmark, see loadmode.go
+}
+
+// ReleaseDBHandles stops everything holding on to the current db handle, so
+// that the db can be closed or reopened.
+func (bm *Benchmark) ReleaseDBHandles() {
+	bm.StopMergeOperators()
+	bm.ReleaseSharedSequence()
 }
 
 func (bm *Benchmark) PrintHeader() {
 	PrintEnv()
-	fmt.Fprintf(os.Stdout, "Keys:        %d bytes each\n", FLAGS_key_size)
-	fmt.Fprintf(os.Stdout, "Values:      %d bytes each\n", FLAGS_value_size)
+	fmt.Fprintf(os.Stdout, "Keys:        %d bytes each (%s)\n", KeySize(), FLAGS_key_format)
+	if FLAGS_value_size_dist == valueDistFixed {
+		fmt.Fprintf(os.Stdout, "Values:      %d bytes each (%.0f bytes after compression)\n",
+			FLAGS_value_size, float64(FLAGS_value_size)*FLAGS_compression_ratio)
+	} else {
+		min, max := ValueSizeBounds(FLAGS_value_size)
+		fmt.Fprintf(os.Stdout, "Values:      %.0f bytes on average, %s in [%d, %d] (%.0f%% after compression)\n",
+			MeanValueSize(FLAGS_value_size), FLAGS_value_size_dist, min, max, FLAGS_compression_ratio*100)
+	}
 	fmt.Fprintf(os.Stdout, "Entries:     %d\n", bm.num)
+	fmt.Fprintf(os.Stdout, "Seed:        %d\n", FLAGS_seed)
+	if FLAGS_write_mode == writeModeBatchN || FLAGS_write_mode == writeModeTxn {
+		fmt.Fprintf(os.Stdout, "WriteMode:   %s (%d per batch)\n", FLAGS_write_mode, FLAGS_batch_size)
+	} else if FLAGS_write_mode != "" {
+		fmt.Fprintf(os.Stdout, "WriteMode:   %s\n", FLAGS_write_mode)
+	}
+	if FLAGS_key_dist != "" {
+		fmt.Fprintf(os.Stdout, "KeyDist:     %s\n", FormatKeyDist(FLAGS_key_dist))
+	}
+	if FLAGS_engine == kBadgerEngine {
+		fmt.Fprintf(os.Stdout, "LoadingMode: tables %s, vlog %s\n", tableLoadingMode, vlogLoadingMode)
+	} else {
+		fmt.Fprintf(os.Stdout, "Engine:      %s\n", FLAGS_engine)
+	}
 	fmt.Fprintf(os.Stdout, "RawSize:     %.1f MB (estimated)\n",
-		float64((FLAGS_key_size+bm.valueSize)*bm.num)/1048576.0)
+		(float64(KeySize())+MeanValueSize(bm.valueSize))*float64(bm.num)/1048576.0)
 	fmt.Fprintf(os.Stdout, "------------------------------------------------\n")
 }
 
@@ -361,13 +848,12 @@ func MakeBenchmark() *Benchmark {
 		bm.db = nil
 		bm.num = FLAGS_num
 		bm.valueSize = FLAGS_value_size
-		bm.entriesPerBatch = 1
+		bm.entriesPerBatch = FLAGS_batch_size
 		if FLAGS_reads < 0 {
 			bm.reads = FLAGS_num
 		} else {
 			bm.reads = FLAGS_reads
 		}
-		bm.totalThreadsCount = 0
 
 		if !FLAGS_use_existing_db {
 			os.RemoveAll(FLAGS_db)
@@ -383,7 +869,7 @@ func ThreadBody(v interface{}) {
 	{
 		shared.cv.L.Lock()
 		shared.numInitialized++
-		if shared.numInitialized >= shared.total {
+		if shared.numInitialized >= shared.total+shared.background {
 			shared.cv.Broadcast()
 		}
 		for !shared.start {
@@ -398,62 +884,91 @@ func ThreadBody(v interface{}) {
 
 	{
 		shared.cv.L.Lock()
-		shared.numDone++
-		if shared.numDone >= shared.total {
-			shared.cv.Broadcast()
+		if arg.background {
+			shared.numBackgroundDone++
+		} else {
+			shared.numDone++
 		}
+		shared.cv.Broadcast()
 		shared.cv.L.Unlock()
 	}
 }
 
 func CreateDBOption() badger.Options {
 	opt := badger.DefaultOptions(FLAGS_db)
+	if FLAGS_leveldb_opt {
+		leveldbDefaultOption(&opt)
+	}
 	opt.MaxTableSize = FLAGS_write_buffer_size
 	opt.ValueLogMaxEntries = FLAGS_vlog_max_entries
 	opt.NumMemtables = FLAGS_memtable_num
 	opt.ValueThreshold = FLAGS_value_threshold
 	opt.NumLevelZeroTables = FLAGS_num_level0
 	opt.NumLevelZeroTablesStall = FLAGS_num_level0_stall
-	if FLAGS_leveldb_opt {
-		leveldbDefaultOption(&opt)
-	}
+	opt.NumVersionsToKeep = FLAGS_num_versions_to_keep
+	opt.TableLoadingMode = loadingModes[tableLoadingMode]
+	opt.ValueLogLoadingMode = loadingModes[vlogLoadingMode]
 	return opt
 }
 
 func (bm *Benchmark) Open(opt badger.Options) {
 	var err error
+	if FLAGS_engine != kBadgerEngine {
+		if bm.engine, err = bDB.Engines[FLAGS_engine](FLAGS_db); err != nil {
+			fmt.Fprintf(os.Stderr, "err occurs when open db: %s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
 	bm.db = bDB.MakeDB()
-	if err = bm.db.Open(opt); err != nil {
+	bm.engine = bm.db
+	if FLAGS_managed {
+		err = bm.db.OpenManaged(opt)
+	} else {
+		err = bm.db.Open(opt)
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "err occurs when open db: %s\n", err.Error())
 		os.Exit(1)
 	}
 }
 
-func (bm *Benchmark) RunBenchmark(n int, name string, method func(*Benchmark, *ThreadState)) {
+// RunBenchmark runs method in n threads and reports their merged stats. If
+// background is not nil, one extra thread runs it until all n threads are
+// done; its stats are reported separately and not merged into the result.
+func (bm *Benchmark) RunBenchmark(n int, name string, method func(*Benchmark, *ThreadState),
+	background func(*Benchmark, *ThreadState)) {
 	shared := MakeSharedState(n)
+	if background != nil {
+		shared.background = 1
+	}
+	total := n + shared.background
 
-	args := make([]ThreadArg, n)
-	for i := 0; i < n; i++ {
+	args := make([]ThreadArg, total)
+	for i := 0; i < total; i++ {
 		args[i].bm = bm
 		args[i].method = method
 		args[i].shared = shared
-		bm.totalThreadsCount++
-		// Seed the thread's random state deterministically based upon thread
-		// creation across all benchmarks. This ensures that the seeds are unique
-		// but reproducible when rerunning the same set of benchmarks.
-		args[i].thread = MakeThreadState(i, int64(1000+bm.totalThreadsCount /*seed*/))
+		if i >= n {
+			args[i].method = background
+			args[i].background = true
+		}
+		// Seed the thread's random state deterministically based upon --seed,
+		// the benchmark and the thread. This ensures that the seeds are unique
+		// but reproducible, whatever other benchmarks are run.
+		args[i].thread = MakeThreadState(i, name)
 		args[i].thread.shared = shared
 		go ThreadBody(&args[i])
 	}
 
 	shared.cv.L.Lock()
-	for shared.numInitialized < n {
+	for shared.numInitialized < total {
 		shared.cv.Wait()
 	}
 
 	shared.start = true
 	shared.cv.Broadcast()
-	for shared.numDone < n {
+	for shared.numDone < n || shared.numBackgroundDone < shared.background {
 		shared.cv.Wait()
 	}
 	shared.cv.L.Unlock()
@@ -461,43 +976,58 @@ func (bm *Benchmark) RunBenchmark(n int, name string, method func(*Benchmark, *T
 	for i := 1; i < n; i++ {
 		args[0].thread.stats.Merge(&args[i].thread.stats)
 	}
-	args[0].thread.stats.Report(name)
+	bm.report(&args[0].thread.stats, name)
+	for i := n; i < total; i++ {
+		bm.report(&args[i].thread.stats, name+".bg")
+	}
 
 }
 
+func (bm *Benchmark) report(stats *Stats, name string) {
+	stats.Report(name)
+	bm.results = append(bm.results, benchResult{name, stats.MicrosPerOp(), stats.OpsPerSec()})
+}
+
 // ======================================
 //
 //	Work Function
 //
 // ======================================
 func (bm *Benchmark) DoWrite(thread *ThreadState, seq bool) {
+	bm.doWrite(thread, seq, 0)
+}
+
+// doWrite writes bm.num keys through one WriteBatch. The keys expire after
+// ttl unless it is 0.
+func (bm *Benchmark) doWrite(thread *ThreadState, seq bool, ttl time.Duration) {
 	if bm.num == FLAGS_num {
 		msg := fmt.Sprintf("(%d ops)", bm.num)
 		thread.stats.AddMsg(msg)
 	}
 
 	var bytes int64 = 0
-	rnd := rand.New(rand.NewSource(301))
-	wb := bm.db.NewWriteBatch()
-	value := RandomString(rnd, bm.valueSize)
-	defer wb.Cancel()
+	rnd := thread.NewRand("values")
+	w := bm.NewBatchWriter(WriteMode(writeModeBatch))
+	thread.stats.AddMsg(w.String())
+	values := NewValueGenerator(rnd, bm.valueSize)
 	for i := 0; i < bm.num; i++ {
 		var k int
 		if seq {
 			k = i
 		} else {
-			k = thread.rd.Intn(FLAGS_num)
+			k = thread.NextKey(FLAGS_num)
 		}
 		key := GenKey(k)
-		if err := wb.SetEntry(badger.NewEntry([]byte(key), []byte(value)).WithMeta(0)); err != nil {
+		value := values.Next()
+		if err := w.SetTTL([]byte(key), value, ttl); err != nil {
 			fmt.Fprintf(os.Stderr, "put error: %s\n", err.Error())
 			os.Exit(1)
 		}
 
-		bytes += int64(bm.valueSize) + int64(len(key))
+		bytes += int64(len(value)) + int64(len(key))
 		thread.stats.FinishedSingleOp()
 	}
-	if err := wb.Flush(); err != nil {
+	if err := w.Flush(); err != nil {
 		fmt.Fprintf(os.Stderr, "put errror: %s\n", err.Error())
 		os.Exit(1)
 	}
@@ -511,18 +1041,25 @@ func (bm *Benchmark) WriteSync(thread *ThreadState) {
 	}
 
 	var bytes int64 = 0
-	rnd := rand.New(rand.NewSource(301))
-	value := RandomString(rnd, bm.valueSize)
+	rnd := thread.NewRand("values")
+	w := bm.NewBatchWriter(WriteMode(writeModeBatchN))
+	thread.stats.AddMsg(w.String())
+	values := NewValueGenerator(rnd, bm.valueSize)
 	for i := 0; i < bm.num; i++ {
-		k := thread.rd.Intn(FLAGS_num)
+		k := thread.NextKey(FLAGS_num)
 		key := GenKey(k)
-		if err := bm.db.Put(key, value); err != nil {
+		value := values.Next()
+		if err := w.Set([]byte(key), value); err != nil {
 			fmt.Fprintf(os.Stderr, "put errror: %s\n", err.Error())
 			os.Exit(1)
 		}
-		bytes += int64(bm.valueSize) + int64(len(key))
+		bytes += int64(len(value)) + int64(len(key))
 		thread.stats.FinishedSingleOp()
 	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "put errror: %s\n", err.Error())
+		os.Exit(1)
+	}
 	thread.stats.AddBytes(bytes)
 }
 
@@ -534,97 +1071,445 @@ func (bm *Benchmark) WriteRandom(thread *ThreadState) {
 	bm.DoWrite(thread, false)
 }
 
+func (bm *Benchmark) DoDelete(thread *ThreadState, seq bool, txn bool) {
+	if bm.num == FLAGS_num {
+		msg := fmt.Sprintf("(%d ops)", bm.num)
+		thread.stats.AddMsg(msg)
+	}
+
+	var bytes int64 = 0
+	mode := WriteMode(writeModeBatch)
+	if txn {
+		mode = writeModeOp
+	}
+	w := bm.NewBatchWriter(mode)
+	thread.stats.AddMsg(w.String())
+	for i := 0; i < bm.num; i++ {
+		var k int
+		if seq {
+			k = i
+		} else {
+			k = thread.NextKey(FLAGS_num)
+		}
+		key := GenKey(k)
+		if err := w.Delete([]byte(key)); err != nil {
+			fmt.Fprintf(os.Stderr, "delete error: %s\n", err.Error())
+			os.Exit(1)
+		}
+		bytes += int64(len(key))
+		thread.stats.FinishedSingleOp()
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "delete error: %s\n", err.Error())
+		os.Exit(1)
+	}
+	thread.stats.AddBytes(bytes)
+}
+
+func (bm *Benchmark) DeleteSeq(thread *ThreadState) {
+	bm.DoDelete(thread, true, false)
+}
+
+func (bm *Benchmark) DeleteRandom(thread *ThreadState) {
+	bm.DoDelete(thread, false, false)
+}
+
+func (bm *Benchmark) DeleteSeqTxn(thread *ThreadState) {
+	bm.DoDelete(thread, true, true)
+}
+
+func (bm *Benchmark) DeleteRandomTxn(thread *ThreadState) {
+	bm.DoDelete(thread, false, true)
+}
+
 func (bm *Benchmark) VlogGC(thread *ThreadState) {
 	var err error
 	for err = nil; err == nil; err = bm.db.VlogGC(0.00001) {
 	}
 }
 
+func (bm *Benchmark) Compact(thread *ThreadState) {
+	lsmBefore, vlogBefore, err := bm.db.Size()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to get db size: %s\n", err.Error())
+		os.Exit(1)
+	}
+	start := time.Now()
+	if err := bm.db.Compact(CreateDBOption().NumCompactors); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to compact: %s\n", err.Error())
+		os.Exit(1)
+	}
+	elapsed := time.Since(start)
+	thread.stats.FinishedSingleOp()
+	lsmAfter, vlogAfter, err := bm.db.Size()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to get db size: %s\n", err.Error())
+		os.Exit(1)
+	}
+	msg := fmt.Sprintf("(%.3f s, %s)", elapsed.Seconds(),
+		FormatSizeChange(lsmBefore, vlogBefore, lsmAfter, vlogAfter))
+	thread.stats.AddMsg(msg)
+}
+
+func FormatSizeChange(lsmBefore, vlogBefore, lsmAfter, vlogAfter int64) string {
+	return fmt.Sprintf("lsm %.1f MB -> %.1f MB, vlog %.1f MB -> %.1f MB",
+		float64(lsmBefore)/1048576.0, float64(lsmAfter)/1048576.0,
+		float64(vlogBefore)/1048576.0, float64(vlogAfter)/1048576.0)
+}
+
 func (bm *Benchmark) ReadSeq(thread *ThreadState) {
 	i := 0
 	var bytes int64 = 0
-	f := func(txn *badger.Txn) error {
-		iterOpt := badger.DefaultIteratorOptions
-		if FLAGS_read_prefetch_size > 0 {
-			iterOpt.PrefetchValues = true
-			iterOpt.PrefetchSize = FLAGS_read_prefetch_size
-		} else {
-			iterOpt.PrefetchValues = true
-			iterOpt.PrefetchSize = 0
-		}
-		iter := txn.NewIterator(iterOpt)
-		defer iter.Close()
-		for iter.Rewind(); i < bm.reads && iter.Valid(); iter.Next() {
-			item := iter.Item()
-			key := item.Key()
-			bytes += int64(len(key))
-			err := item.Value(func(v []byte) error {
+	iterOpt := bDB.IteratorOptions{PrefetchValues: true}
+	if FLAGS_read_prefetch_size > 0 {
+		iterOpt.PrefetchSize = FLAGS_read_prefetch_size
+	}
+	iter := bm.engine.NewIterator(iterOpt)
+	defer iter.Close()
+	for iter.Rewind(); i < bm.reads && iter.Valid(); iter.Next() {
+		key := iter.Key()
+		bytes += int64(len(key))
+		err := iter.Value(func(v []byte) error {
+			bytes += int64(len(v))
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to readseq: %s\n", err.Error())
+			os.Exit(1)
+		}
+		thread.stats.FinishedSingleOp()
+		i++
+	}
+	thread.stats.AddBytes(bytes)
+}
+
+func (bm *Benchmark) ReadReverse(thread *ThreadState) {
+	i := 0
+	bytes := 0
+	iterOpt := bDB.IteratorOptions{Reverse: true}
+	if FLAGS_read_prefetch_size > 0 {
+		iterOpt.PrefetchValues = true
+		iterOpt.PrefetchSize = FLAGS_read_prefetch_size
+	}
+	iter := bm.engine.NewIterator(iterOpt)
+	defer iter.Close()
+	for iter.Rewind(); i < bm.reads && iter.Valid(); iter.Next() {
+		key := iter.Key()
+		bytes += len(key)
+		err := iter.Value(func(v []byte) error {
+			bytes += len(v)
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to readreverse: %s\n", err.Error())
+			os.Exit(1)
+		}
+		thread.stats.FinishedSingleOp()
+		i++
+	}
+	thread.stats.AddBytes(int64(bytes))
+}
+
+func (bm *Benchmark) DoSeekRandom(thread *ThreadState, reverse bool) {
+	found := 0
+	var bytes int64 = 0
+	iterOpt := bDB.IteratorOptions{Reverse: reverse}
+	if FLAGS_read_prefetch_size > 0 {
+		iterOpt.PrefetchValues = true
+		iterOpt.PrefetchSize = FLAGS_read_prefetch_size
+	}
+	iter := bm.engine.NewIterator(iterOpt)
+	defer iter.Close()
+	for i := 0; i < bm.reads; i++ {
+		k := thread.NextKey(FLAGS_num)
+		key := GenKey(k)
+		iter.Seek([]byte(key))
+		if iter.Valid() && string(iter.Key()) == key {
+			found++
+		}
+		for j := 0; j < FLAGS_seek_nexts && iter.Valid(); j++ {
+			bytes += int64(len(iter.Key()))
+			err := iter.Value(func(v []byte) error {
 				bytes += int64(len(v))
 				return nil
 			})
 			if err != nil {
-				return err
+				fmt.Fprintf(os.Stderr, "failed to seekrandom: %s\n", err.Error())
+				os.Exit(1)
+			}
+			iter.Next()
+		}
+		thread.stats.FinishedSingleOp()
+	}
+	thread.stats.AddBytes(bytes)
+	thread.stats.AddCounter("reads", int64(bm.reads))
+	thread.stats.AddCounter("found", int64(found))
+	thread.stats.SetSummary(func(s *Stats) string {
+		return fmt.Sprintf("(%.0f scans/sec, %d of %d found, %d nexts per seek)",
+			s.OpsPerSec(), s.counters["found"], s.counters["reads"], FLAGS_seek_nexts)
+	})
+}
+
+func (bm *Benchmark) SeekRandom(thread *ThreadState) {
+	bm.DoSeekRandom(thread, false)
+}
+
+func (bm *Benchmark) SeekRandomReverse(thread *ThreadState) {
+	bm.DoSeekRandom(thread, true)
+}
+
+// DoReadKeys iterates like ReadSeq/ReadReverse but never fetches values.
+// If the engine can tell, it also counts the values kept in a value log.
+func (bm *Benchmark) DoReadKeys(thread *ThreadState, reverse bool) {
+	i := 0
+	var bytes int64 = 0
+	var vlogValues, inlineValues int64
+	iter := bm.engine.NewIterator(bDB.IteratorOptions{Reverse: reverse})
+	defer iter.Close()
+	locator, _ := iter.(bDB.ValueLocator)
+	for iter.Rewind(); i < bm.reads && iter.Valid(); iter.Next() {
+		bytes += int64(len(iter.Key()))
+		if locator != nil {
+			if locator.ValueInVlog() {
+				vlogValues++
+			} else {
+				inlineValues++
+			}
+		}
+		thread.stats.FinishedSingleOp()
+		i++
+	}
+	thread.stats.AddBytes(bytes)
+	if locator == nil {
+		return
+	}
+	thread.stats.AddCounter("vlog", vlogValues)
+	thread.stats.AddCounter("inline", inlineValues)
+	thread.stats.SetSummary(func(s *Stats) string {
+		return fmt.Sprintf("(%d values in vlog, %d inline)", s.counters["vlog"], s.counters["inline"])
+	})
+}
+
+func (bm *Benchmark) ReadKeys(thread *ThreadState) {
+	bm.DoReadKeys(thread, false)
+}
+
+func (bm *Benchmark) ReadKeysReverse(thread *ThreadState) {
+	bm.DoReadKeys(thread, true)
+}
+
+func (bm *Benchmark) ReadRandom(thread *ThreadState) {
+	found := 0
+	for i := 0; i < bm.reads; i++ {
+		k := thread.NextKey(FLAGS_num)
+		if _, err := bm.engine.Get(GenKey(k)); err == nil {
+			found++
+		}
+		thread.stats.FinishedSingleOp()
+	}
+	msg := fmt.Sprintf("(%d of %d found)", found, bm.num)
+	thread.stats.AddMsg(msg)
+}
+
+func (bm *Benchmark) ReadDeleted(thread *ThreadState) {
+	found := 0
+	var missed []string
+	for i := 0; i < bm.reads; i++ {
+		k := thread.NextKey(FLAGS_num)
+		key := GenKey(k)
+		if _, err := bm.db.Get(key); err == nil {
+			found++
+		} else {
+			missed = append(missed, key)
+		}
+		thread.stats.FinishedSingleOp()
+	}
+	thread.stats.Stop()
+	thread.stats.AddCounter("reads", int64(bm.reads))
+	thread.stats.AddCounter("found", int64(found))
+	thread.stats.AddCounter("tombstones", int64(bm.countMisses(missed, bDB.MissDeleted)))
+	thread.stats.SetSummary(func(s *Stats) string {
+		return fmt.Sprintf("(%d of %d found, %d tombstones)", s.counters["found"], s.counters["reads"], s.counters["tombstones"])
+	})
+}
+
+// countMisses tells how many of the keys a read missed did so for reason.
+// It is slow, so the benchmarks call it once the timed reads are done.
+func (bm *Benchmark) countMisses(keys []string, reason int) int {
+	n := 0
+	for _, key := range keys {
+		miss, err := bm.db.MissReason(key)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to look up %s: %s\n", key, err.Error())
+			os.Exit(1)
+		}
+		if miss == reason {
+			n++
+		}
+	}
+	return n
+}
+
+// BackgroundWrite keeps writing random keys until the foreground threads
+// finish. By default every key is committed on its own, as leveldb's
+// readwhilewriting does.
+func (bm *Benchmark) BackgroundWrite(thread *ThreadState) {
+	var bytes int64 = 0
+	rnd := thread.NewRand("values")
+	w := bm.NewBatchWriter(WriteMode(writeModeBatchN))
+	thread.stats.AddMsg(w.String())
+	values := NewValueGenerator(rnd, bm.valueSize)
+	for !thread.shared.ForegroundDone() {
+		k := thread.NextKey(FLAGS_num)
+		key := GenKey(k)
+		value := values.Next()
+		if err := w.Set([]byte(key), value); err != nil {
+			fmt.Fprintf(os.Stderr, "put errror: %s\n", err.Error())
+			os.Exit(1)
+		}
+		bytes += int64(len(value)) + int64(len(key))
+		thread.stats.FinishedSingleOp()
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "put errror: %s\n", err.Error())
+		os.Exit(1)
+	}
+	thread.stats.AddBytes(bytes)
+	thread.stats.AddMsg(fmt.Sprintf("(%d writes)", thread.stats.done))
+}
+
+// MultiReadRandom reads random keys in batches of --multiget_size, each batch
+// inside a single read transaction.
+func (bm *Benchmark) MultiReadRandom(thread *ThreadState) {
+	var found, keysRead int64
+	var bytes int64 = 0
+	keys := make([]string, FLAGS_multiget_size)
+	for i := 0; i < bm.reads; i += len(keys) {
+		if n := bm.reads - i; n < len(keys) {
+			keys = keys[:n]
+		}
+		for j := range keys {
+			keys[j] = GenKey(thread.NextKey(FLAGS_num))
+		}
+		values, n, err := bm.engine.MultiGet(keys)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "multiget error: %s\n", err.Error())
+			os.Exit(1)
+		}
+		for j, v := range values {
+			if v != "" {
+				bytes += int64(len(keys[j]) + len(v))
+			}
+		}
+		found += int64(n)
+		keysRead += int64(len(keys))
+		thread.stats.FinishedSingleOp()
+	}
+	thread.stats.AddBytes(bytes)
+	thread.stats.AddCounter("found", found)
+	thread.stats.AddCounter("keys", keysRead)
+	thread.stats.SetSummary(func(s *Stats) string {
+		keys := s.counters["keys"]
+		perKey := 0.
+		if keys > 0 {
+			perKey = s.seconds * 1e6 / float64(keys)
+		}
+		return fmt.Sprintf("(%d keys per batch, %.3f micros/key, %d of %d found)",
+			FLAGS_multiget_size, perKey, s.counters["found"], keys)
+	})
+}
+
+func (bm *Benchmark) FillPrefix(thread *ThreadState) {
+	var bytes int64 = 0
+	rnd := thread.NewRand("values")
+	w := bm.NewBatchWriter(WriteMode(writeModeBatch))
+	values := NewValueGenerator(rnd, bm.valueSize)
+	prefixes := NumPrefixes()
+	for p := 0; p < prefixes; p++ {
+		for id := 0; id < FLAGS_keys_per_prefix; id++ {
+			key := GenPrefixKey(p, id)
+			value := values.Next()
+			if err := w.Set([]byte(key), value); err != nil {
+				fmt.Fprintf(os.Stderr, "put errror: %s\n", err.Error())
+				os.Exit(1)
 			}
+			bytes += int64(len(value)) + int64(len(key))
 			thread.stats.FinishedSingleOp()
-			i++
 		}
-		return nil
 	}
-	if err := bm.db.DoView(f); err != nil {
-		fmt.Fprintf(os.Stderr, "failed to readseq: %s\n", err.Error())
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "put errror: %s\n", err.Error())
 		os.Exit(1)
 	}
 	thread.stats.AddBytes(bytes)
+	msg := fmt.Sprintf("(%d prefixes, %d keys each) %s", prefixes, FLAGS_keys_per_prefix, w.String())
+	thread.stats.AddMsg(msg)
 }
 
-func (bm *Benchmark) ReadReverse(thread *ThreadState) {
-	i := 0
-	bytes := 0
-	f := func(txn *badger.Txn) error {
-		iterOpt := badger.DefaultIteratorOptions
+// PrefixScan reads every key under random prefixes, doing about --reads rows
+// in total. Each op is the scan of one prefix.
+func (bm *Benchmark) PrefixScan(thread *ThreadState) {
+	var rows int64
+	var bytes int64 = 0
+	prefixes := NumPrefixes()
+	scans := bm.reads / FLAGS_keys_per_prefix
+	if scans < 1 {
+		scans = 1
+	}
+	for i := 0; i < scans; i++ {
+		prefix := []byte(GenPrefix(thread.NextKey(prefixes)))
+		iterOpt := bDB.IteratorOptions{Prefix: prefix, PrefetchValues: true, PrefetchSize: 100}
 		if FLAGS_read_prefetch_size > 0 {
-			iterOpt.PrefetchValues = true
 			iterOpt.PrefetchSize = FLAGS_read_prefetch_size
-		} else {
-			iterOpt.PrefetchValues = false
-			iterOpt.PrefetchSize = 0
-		}
-		iterOpt.Reverse = true
-		iter := txn.NewIterator(iterOpt)
-		defer iter.Close()
-		for iter.Rewind(); i < bm.reads && iter.Valid(); iter.Next() {
-			item := iter.Item()
-			key := item.Key()
-			bytes += len(key)
-			err := item.Value(func(v []byte) error {
-				bytes += len(v)
+		}
+		iter := bm.engine.NewIterator(iterOpt)
+		var err error
+		for iter.Seek(prefix); err == nil && iter.Valid(); iter.Next() {
+			bytes += int64(len(iter.Key()))
+			err = iter.Value(func(v []byte) error {
+				bytes += int64(len(v))
 				return nil
 			})
-			if err != nil {
-				return err
-			}
-			thread.stats.FinishedSingleOp()
-			i++
+			rows++
+		}
+		iter.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to prefixscan: %s\n", err.Error())
+			os.Exit(1)
 		}
-		return nil
+		thread.stats.FinishedSingleOp()
 	}
-	if err := bm.db.DoView(f); err != nil {
-		fmt.Fprintf(os.Stderr, "failed to readreverse: %s\n", err.Error())
-		os.Exit(1)
+	thread.stats.AddBytes(bytes)
+	thread.stats.AddCounter("rows", rows)
+	thread.stats.SetSummary(func(s *Stats) string {
+		rowsPerSec := 0.
+		if elapsed := (s.finish - s.start) * 1e-6; elapsed > 0 {
+			rowsPerSec = float64(s.counters["rows"]) / elapsed
+		}
+		return fmt.Sprintf("(%d rows, %.0f rows/sec)", s.counters["rows"], rowsPerSec)
+	})
+}
+
+// HotRange is the number of keys readhot reads from.
+func HotRange() int {
+	hotRange := int(float64(FLAGS_num) * FLAGS_hot_ratio)
+	if hotRange < 1 {
+		hotRange = 1
 	}
-	thread.stats.AddBytes(int64(bytes))
+	return hotRange
 }
 
-func (bm *Benchmark) ReadRandom(thread *ThreadState) {
+func (bm *Benchmark) ReadHot(thread *ThreadState) {
+	hotRange := HotRange()
 	found := 0
 	for i := 0; i < bm.reads; i++ {
-		k := thread.rd.Intn(FLAGS_num)
-		if _, err := bm.db.Get(GenKey(k)); err == nil {
+		k := thread.NextKey(hotRange)
+		if _, err := bm.engine.Get(GenKey(k)); err == nil {
 			found++
 		}
 		thread.stats.FinishedSingleOp()
 	}
-	msg := fmt.Sprintf("(%d of %d found)", found, bm.num)
+	msg := fmt.Sprintf("(%d of %d found, hot range %d)", found, bm.reads, hotRange)
 	thread.stats.AddMsg(msg)
 }
 
@@ -642,12 +1527,32 @@ func (bm *Benchmark) Run() {
 			bm.reads = FLAGS_reads
 		}
 		bm.valueSize = FLAGS_value_size
-		bm.entriesPerBatch = 1
+		bm.entriesPerBatch = FLAGS_batch_size
 		var method func(*Benchmark, *ThreadState)
+		var bgMethod func(*Benchmark, *ThreadState)
 		freshDB := false
 		numThreads := FLAGS_threads
 		cleandb := true
 		dbOpt = CreateDBOption()
+		// checked before the switch, as some cases already set up badger
+		// handles (merge operators, sequences, subscribers) which the engine
+		// or managed mode may not support
+		if FLAGS_engine != kBadgerEngine && benchmark != "" && !engineSupports(benchmark) {
+			fmt.Fprintf(os.Stderr, "benchmark '%s' does not support --engine %s\n", benchmark, FLAGS_engine)
+			continue
+		}
+		if benchmark != "" && FLAGS_managed && !managedBenchmarks[benchmark] {
+			fmt.Fprintf(os.Stderr, "benchmark '%s' does not support --managed\n", benchmark)
+			continue
+		}
+		if benchmark != "" && !FLAGS_managed && managedOnlyBenchmarks[benchmark] {
+			fmt.Fprintf(os.Stderr, "benchmark '%s' needs --managed\n", benchmark)
+			continue
+		}
+		if benchmark == "subscribe" && !commitsEachWrite() {
+			fmt.Fprintf(os.Stderr, "benchmark '%s' needs one commit per write: --write_mode op, or batchn or txn with --batch_size 1\n", benchmark)
+			continue
+		}
 		switch benchmark {
 		case "fillseq":
 			freshDB = true
@@ -671,20 +1576,138 @@ func (bm *Benchmark) Run() {
 			method = (*Benchmark).ReadSeq
 		case "readreverse":
 			method = (*Benchmark).ReadReverse
+		case "readkeys":
+			method = (*Benchmark).ReadKeys
+		case "readkeysreverse":
+			method = (*Benchmark).ReadKeysReverse
 		case "readrandom":
 			method = (*Benchmark).ReadRandom
+		case "readdeleted":
+			method = (*Benchmark).ReadDeleted
+		case "deleteseq":
+			method = (*Benchmark).DeleteSeq
+		case "deleterandom":
+			method = (*Benchmark).DeleteRandom
+		case "deleteseqtxn":
+			method = (*Benchmark).DeleteSeqTxn
+		case "deleterandomtxn":
+			method = (*Benchmark).DeleteRandomTxn
+		case "seekrandom":
+			method = (*Benchmark).SeekRandom
+		case "seekrandomreverse":
+			method = (*Benchmark).SeekRandomReverse
+		case "readwhilewriting":
+			method = (*Benchmark).ReadRandom
+			bgMethod = (*Benchmark).BackgroundWrite
+		case "subscribe":
+			bm.StartSubscribers()
+			method = (*Benchmark).PublishRandom
+			bgMethod = (*Benchmark).CollectNotifications
+		case "multireadrandom":
+			method = (*Benchmark).MultiReadRandom
+		case "fillprefix":
+			freshDB = true
+			method = (*Benchmark).FillPrefix
+		case "prefixscan":
+			method = (*Benchmark).PrefixScan
+		case "readhot":
+			method = (*Benchmark).ReadHot
+		case "txnrmw":
+			method = (*Benchmark).TxnReadModifyWrite
+		case "mergerandom":
+			bm.OpenMergeOperators()
+			method = (*Benchmark).MergeRandom
+		case "countertxn":
+			bm.allocCounters()
+			method = (*Benchmark).CounterTxn
+		case "mergeverify":
+			numThreads = 1
+			bm.allocCounters()
+			method = (*Benchmark).MergeVerify
+		case "fillttl":
+			freshDB = true
+			method = (*Benchmark).WriteSeqTTL
+		case "fillttlrandom":
+			freshDB = true
+			method = (*Benchmark).WriteRandomTTL
+		case "waitttl":
+			numThreads = 1
+			method = (*Benchmark).WaitTTL
+		case "readttl":
+			method = (*Benchmark).ReadTTL
+		case "reclaim":
+			bm.ReleaseDBHandles()
+			numThreads = 1
+			method = (*Benchmark).Reclaim
+		case "stream":
+			numThreads = 1
+			method = (*Benchmark).StreamScan
+		case "backup":
+			numThreads = 1
+			method = (*Benchmark).FullBackup
+		case "backupinc":
+			numThreads = 1
+			method = (*Benchmark).IncrementalBackup
+		case "restore":
+			bm.OpenRestoreDB()
+			numThreads = 1
+			method = (*Benchmark).Restore
+		case "sequence":
+			bm.OpenSharedSequence()
+			method = (*Benchmark).SequenceShared
+		case "sequencethread":
+			method = (*Benchmark).SequencePerThread
+		case "sequencereopen":
+			bm.ReleaseDBHandles()
+			numThreads = 1
+			method = (*Benchmark).SequenceReopen
+		case "fillversions":
+			freshDB = true
+			method = (*Benchmark).FillVersions
+		case "readold":
+			method = (*Benchmark).ReadOldVersion
+		case "readlatest":
+			method = (*Benchmark).ReadLatestVersion
+		case "open":
+			bm.ReleaseDBHandles()
+			bm.CloseDB()
+			numThreads = 1
+			method = (*Benchmark).OpenClean
+		case "opencrash":
+			bm.ReleaseDBHandles()
+			bm.CloseDB()
+			bm.CrashDB()
+			numThreads = 1
+			method = (*Benchmark).OpenCrash
+		case "compact":
+			// compaction reopens the db under the merge operators
+			bm.ReleaseDBHandles()
+			numThreads = 1
+			method = (*Benchmark).Compact
 		case "fill100k":
 			freshDB = true
 			bm.num /= 1000
 			bm.valueSize = 100 * 1000
 			method = (*Benchmark).WriteRandom
 		default:
-			if benchmark != "" {
+			if w, ok := ycsbWorkloads[benchmark]; ok {
+				method = func(bm *Benchmark, thread *ThreadState) {
+					bm.YCSB(thread, w)
+				}
+			} else if benchmark != "" {
 				fmt.Fprintf(os.Stderr, "unknown benchmark '%s'\n", benchmark)
 			}
 		}
 		if freshDB {
-			bm.db.Close()
+			bm.inserted = 0
+			bm.latestTs = 0
+			bm.backupFiles = nil
+			bm.backupSince = 0
+			bm.backupKeys = 0
+			bm.ReleaseDBHandles()
+			bm.mergeAdds = nil
+			bm.counterAdds = nil
+			bm.engine.Close()
 			if cleandb {
 				// if err := os.RemoveAll(FLAGS_db); err != nil {
 				// 	fmt.Fprintf(os.Stderr, "failed to drop db: %s\n", err.Error())
@@ -695,8 +1718,9 @@ func (bm *Benchmark) Run() {
 		}
 
 		if method != nil {
-			bm.RunBenchmark(numThreads, benchmark, method)
+			bm.RunBenchmark(numThreads, benchmark, method, bgMethod)
 		}
+		bm.StopMergeOperators()
 	}
 }
 
@@ -709,25 +1733,200 @@ func Init() {
 	FLAGS_num_level0_stall = default_opt.NumLevelZeroTablesStall
 }
 
+// leveldbDefaultFlags makes the flags leveldbDefaultOption overrides default
+// to its values, so that the ones given on the command line still win.
+func leveldbDefaultFlags() {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	opt := badger.DefaultOptions("")
+	leveldbDefaultOption(&opt)
+	if !set["write_buffer_size"] {
+		FLAGS_write_buffer_size = opt.MaxTableSize
+	}
+	if !set["mem_table_num"] {
+		FLAGS_memtable_num = opt.NumMemtables
+	}
+	if !set["num_level0"] {
+		FLAGS_num_level0 = opt.NumLevelZeroTables
+	}
+}
+
 func main() {
 	Init()
 	var benchmarks string
+	tableLoadingModes := strings.Join(FLAGS_table_loading_mode, ",")
+	vlogLoadingModes := strings.Join(FLAGS_vlog_loading_mode, ",")
 	flag.StringVar(&benchmarks, "benchmarks", strings.Join(FLAGS_benchmarks, `,`), "benchmarks")
 	flag.BoolVar(&FLAGS_leveldb_opt, "leveldb", FLAGS_leveldb_opt, "use leveldb default option")
 	flag.IntVar(&FLAGS_num, "num", FLAGS_num, "Number of key/values to place in database")
+	flag.IntVar(&FLAGS_reads, "reads", FLAGS_reads, "Number of read operations to do (default num)")
 	flag.IntVar(&FLAGS_value_size, "value_size", FLAGS_value_size, "Size of each value")
+	flag.StringVar(&FLAGS_value_size_dist, "value_size_dist", FLAGS_value_size_dist, "Distribution of the value sizes: fixed, uniform, normal or zipf")
+	flag.IntVar(&FLAGS_value_size_min, "value_size_min", FLAGS_value_size_min, "Smallest value size (default value_size/2)")
+	flag.IntVar(&FLAGS_value_size_max, "value_size_max", FLAGS_value_size_max, "Largest value size (default 2*value_size)")
+	flag.Float64Var(&FLAGS_compression_ratio, "compression_ratio", FLAGS_compression_ratio, "Values compress to about this fraction of their size")
 	flag.IntVar(&FLAGS_value_threshold, "value_threshold", FLAGS_value_threshold, "value threshold to trigger key/value separate")
 	flag.Int64Var(&FLAGS_write_buffer_size, "write_buffer_size", FLAGS_write_buffer_size, "Size of table")
 	flag.IntVar(&FLAGS_threads, "threads", FLAGS_threads, "Number of concurrent threads to run")
+	flag.IntVar(&FLAGS_batch_size, "batch_size", FLAGS_batch_size, "Number of entries per batch in the batchn and txn write modes")
+	flag.StringVar(&FLAGS_write_mode, "write_mode", FLAGS_write_mode, "Write path: batch, batchn, txn or op (default: per benchmark)")
 	flag.IntVar(&FLAGS_memtable_num, "mem_table_num", FLAGS_memtable_num, "Number of memtables")
 	flag.IntVar(&FLAGS_num_level0, "num_level0", FLAGS_num_level0, "Number of tables at level0")
 	flag.IntVar(&FLAGS_num_level0_stall, "num_level0_stall", FLAGS_num_level0_stall, "Number of stalled tables at level0")
+	flag.StringVar(&tableLoadingModes, "table_loading_mode", tableLoadingModes, "Loading mode of the LSM tables: fileio, mmap or ram; a comma-separated list runs the benchmarks once per mode")
+	flag.StringVar(&vlogLoadingModes, "vlog_loading_mode", vlogLoadingModes, "Loading mode of the value log: fileio or mmap; a comma-separated list runs the benchmarks once per mode")
 	flag.IntVar(&FLAGS_read_prefetch_size, "read_prefetch_size", FLAGS_read_prefetch_size, "KV pairs to prefetch while iterating.")
 	flag.StringVar(&FLAGS_db, "db", FLAGS_db, "database path")
+	flag.IntVar(&FLAGS_key_size, "key_size", FLAGS_key_size, "Size of each key")
+	flag.StringVar(&FLAGS_key_format, "key_format", FLAGS_key_format, "Key encoding: decimal, binary, random or prefix")
+	flag.StringVar(&FLAGS_key_dist, "key_dist", FLAGS_key_dist, "Key distribution: uniform, zipfian, latest, hotspot or exponential (default uniform, ycsb workloads use their own)")
+	flag.Float64Var(&FLAGS_zipf_theta, "zipf_theta", FLAGS_zipf_theta, "Skew of the zipfian and latest key distributions, in (0, 1)")
+	flag.Float64Var(&FLAGS_hotspot_ops_fraction, "hotspot_ops_fraction", FLAGS_hotspot_ops_fraction, "Fraction of the ops sent to the hot keys by the hotspot key distribution")
+	flag.Float64Var(&FLAGS_hotspot_set_fraction, "hotspot_set_fraction", FLAGS_hotspot_set_fraction, "Fraction of the keys that are hot in the hotspot key distribution")
+	flag.Float64Var(&FLAGS_exp_percentile, "exp_percentile", FLAGS_exp_percentile, "Percentage of the ops the exponential key distribution puts in the first exp_fraction of the keys")
+	flag.Float64Var(&FLAGS_exp_fraction, "exp_fraction", FLAGS_exp_fraction, "Fraction of the keys receiving exp_percentile percent of the ops in the exponential key distribution")
+	flag.Int64Var(&FLAGS_seed, "seed", FLAGS_seed, "Seed of every random stream (keys, values, distributions, op mix)")
+	flag.StringVar(&FLAGS_engine, "engine", FLAGS_engine, "Storage engine: badger or memory")
 	flag.BoolVar(&FLAGS_histogram, "histogram", FLAGS_histogram, "whether output histogram")
-	
+	flag.IntVar(&FLAGS_seek_nexts, "seek_nexts", FLAGS_seek_nexts, "Number of Next() calls after each Seek() in seekrandom")
+	flag.IntVar(&FLAGS_txn_hot_keys, "txn_hot_keys", FLAGS_txn_hot_keys, "Number of keys contended for by txnrmw")
+	flag.IntVar(&FLAGS_txn_retries, "txn_retries", FLAGS_txn_retries, "Times a conflicting txnrmw transaction is retried")
+	flag.IntVar(&FLAGS_merge_keys, "merge_keys", FLAGS_merge_keys, "Number of counters updated by mergerandom and countertxn")
+	flag.StringVar(&FLAGS_merge_func, "merge_func", FLAGS_merge_func, "Merge function of mergerandom: add or append")
+	flag.DurationVar(&FLAGS_merge_interval, "merge_interval", FLAGS_merge_interval, "How often the merge operators compact their operands")
+	flag.DurationVar(&FLAGS_ttl, "ttl", FLAGS_ttl, "TTL of the keys written by fillttl and fillttlrandom")
+	flag.Float64Var(&FLAGS_gc_discard_ratio, "gc_discard_ratio", FLAGS_gc_discard_ratio, "Discard ratio of the value log GC run by reclaim")
+	flag.IntVar(&FLAGS_multiget_size, "multiget_size", FLAGS_multiget_size, "Number of keys looked up per transaction by multireadrandom")
+	flag.IntVar(&FLAGS_prefixes, "prefixes", FLAGS_prefixes, "Number of prefixes written by fillprefix (default num/keys_per_prefix)")
+	flag.IntVar(&FLAGS_keys_per_prefix, "keys_per_prefix", FLAGS_keys_per_prefix, "Number of keys under each prefix written by fillprefix")
+	flag.IntVar(&FLAGS_stream_num_go, "stream_num_go", FLAGS_stream_num_go, "Number of goroutines used by the stream benchmark")
+	flag.StringVar(&FLAGS_stream_prefix, "stream_prefix", FLAGS_stream_prefix, "Only keys with this prefix are scanned by the stream benchmark")
+	flag.StringVar(&FLAGS_backup_dir, "backup_dir", FLAGS_backup_dir, "Directory of backup files (default <db>.backup)")
+	flag.StringVar(&FLAGS_restore_dir, "restore_dir", FLAGS_restore_dir, "Directory restore loads backups into (default <db>.restore)")
+	flag.IntVar(&FLAGS_subscribers, "subscribers", FLAGS_subscribers, "Number of subscribers of the subscribe benchmark")
+	flag.StringVar(&FLAGS_subscribe_prefix, "subscribe_prefix", FLAGS_subscribe_prefix, "Key prefix watched by the subscribers")
+	flag.DurationVar(&FLAGS_subscribe_drain, "subscribe_drain", FLAGS_subscribe_drain, "How long subscribers may lag behind after the writers finish")
+	flag.Uint64Var(&FLAGS_seq_bandwidth, "seq_bandwidth", FLAGS_seq_bandwidth, "Number of integers leased at a time by the sequence benchmarks")
+	flag.BoolVar(&FLAGS_managed, "managed", FLAGS_managed, "Open the db in managed mode (user supplied timestamps)")
+	flag.IntVar(&FLAGS_versions, "versions", FLAGS_versions, "Number of versions written per key by fillversions")
+	flag.IntVar(&FLAGS_num_versions_to_keep, "num_versions_to_keep", FLAGS_num_versions_to_keep, "Max versions kept per key once older than discard_ts")
+	flag.Uint64Var(&FLAGS_discard_ts, "discard_ts", FLAGS_discard_ts, "Timestamp passed to SetDiscardTs after fillversions (0 keeps every version)")
+	flag.DurationVar(&FLAGS_crash_after, "crash_after", FLAGS_crash_after, "How long the opencrash child writes before it is killed")
+	flag.BoolVar(&FLAGS_crash_child, "crash_child", FLAGS_crash_child, "internal: run as the child process of opencrash")
+	flag.Float64Var(&FLAGS_hot_ratio, "hot_ratio", FLAGS_hot_ratio, "Fraction of the key space read by readhot")
+
 	flag.Parse()
+	if FLAGS_backup_dir == "" {
+		FLAGS_backup_dir = FLAGS_db + ".backup"
+	}
+	if FLAGS_restore_dir == "" {
+		FLAGS_restore_dir = FLAGS_db + ".restore"
+	}
+	if FLAGS_write_mode != "" && !writeModes[FLAGS_write_mode] {
+		fmt.Fprintf(os.Stderr, "unknown write_mode '%s'\n", FLAGS_write_mode)
+		os.Exit(1)
+	}
+	if bDB.MergeFuncs[FLAGS_merge_func] == nil {
+		fmt.Fprintf(os.Stderr, "unknown merge_func '%s'\n", FLAGS_merge_func)
+		os.Exit(1)
+	}
+	if FLAGS_multiget_size < 1 {
+		fmt.Fprintf(os.Stderr, "invalid multiget_size %d\n", FLAGS_multiget_size)
+		os.Exit(1)
+	}
+	if FLAGS_keys_per_prefix < 1 {
+		fmt.Fprintf(os.Stderr, "invalid keys_per_prefix %d\n", FLAGS_keys_per_prefix)
+		os.Exit(1)
+	}
+	if FLAGS_merge_keys < 1 {
+		fmt.Fprintf(os.Stderr, "invalid merge_keys %d\n", FLAGS_merge_keys)
+		os.Exit(1)
+	}
+	if FLAGS_merge_interval <= 0 {
+		fmt.Fprintf(os.Stderr, "invalid merge_interval %s\n", FLAGS_merge_interval)
+		os.Exit(1)
+	}
+	if FLAGS_txn_hot_keys < 1 {
+		fmt.Fprintf(os.Stderr, "invalid txn_hot_keys %d\n", FLAGS_txn_hot_keys)
+		os.Exit(1)
+	}
+	if FLAGS_stream_num_go < 1 {
+		fmt.Fprintf(os.Stderr, "invalid stream_num_go %d\n", FLAGS_stream_num_go)
+		os.Exit(1)
+	}
+	if FLAGS_subscribers < 1 {
+		fmt.Fprintf(os.Stderr, "invalid subscribers %d\n", FLAGS_subscribers)
+		os.Exit(1)
+	}
+	if FLAGS_seq_bandwidth < 1 {
+		fmt.Fprintf(os.Stderr, "invalid seq_bandwidth %d\n", FLAGS_seq_bandwidth)
+		os.Exit(1)
+	}
+	if _, ok := bDB.Engines[FLAGS_engine]; !ok && FLAGS_engine != kBadgerEngine {
+		fmt.Fprintf(os.Stderr, "unknown engine %q\n", FLAGS_engine)
+		os.Exit(1)
+	}
+	if FLAGS_managed && FLAGS_engine != kBadgerEngine {
+		fmt.Fprintf(os.Stderr, "--managed needs --engine %s\n", kBadgerEngine)
+		os.Exit(1)
+	}
+	if !valueDists[FLAGS_value_size_dist] {
+		fmt.Fprintf(os.Stderr, "unknown value_size_dist %q\n", FLAGS_value_size_dist)
+		os.Exit(1)
+	}
+	if min, max := ValueSizeBounds(FLAGS_value_size); FLAGS_value_size_dist != valueDistFixed && (min < 0 || max < min) {
+		fmt.Fprintf(os.Stderr, "invalid value_size_min %d or value_size_max %d\n", min, max)
+		os.Exit(1)
+	}
+	if FLAGS_compression_ratio <= 0 || FLAGS_compression_ratio > 1 {
+		fmt.Fprintf(os.Stderr, "invalid compression_ratio %v, must be in (0, 1]\n", FLAGS_compression_ratio)
+		os.Exit(1)
+	}
+	if err := SetupKeyFormat(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+	if FLAGS_key_dist != "" && !keyDists[FLAGS_key_dist] {
+		fmt.Fprintf(os.Stderr, "unknown key_dist %q\n", FLAGS_key_dist)
+		os.Exit(1)
+	}
+	if FLAGS_zipf_theta <= 0 || FLAGS_zipf_theta >= 1 {
+		fmt.Fprintf(os.Stderr, "invalid zipf_theta %v, must be in (0, 1)\n", FLAGS_zipf_theta)
+		os.Exit(1)
+	}
+	if FLAGS_hotspot_ops_fraction < 0 || FLAGS_hotspot_ops_fraction > 1 ||
+		FLAGS_hotspot_set_fraction <= 0 || FLAGS_hotspot_set_fraction > 1 {
+		fmt.Fprintf(os.Stderr, "invalid hotspot_ops_fraction %v or hotspot_set_fraction %v\n",
+			FLAGS_hotspot_ops_fraction, FLAGS_hotspot_set_fraction)
+		os.Exit(1)
+	}
+	if FLAGS_exp_percentile <= 0 || FLAGS_exp_percentile >= 100 || FLAGS_exp_fraction <= 0 {
+		fmt.Fprintf(os.Stderr, "invalid exp_percentile %v or exp_fraction %v\n", FLAGS_exp_percentile, FLAGS_exp_fraction)
+		os.Exit(1)
+	}
+	if FLAGS_hot_ratio <= 0 || FLAGS_hot_ratio > 1 {
+		fmt.Fprintf(os.Stderr, "invalid hot_ratio %v, must be in (0, 1]\n", FLAGS_hot_ratio)
+		os.Exit(1)
+	}
+	FLAGS_table_loading_mode = strings.Split(tableLoadingModes, ",")
+	FLAGS_vlog_loading_mode = strings.Split(vlogLoadingModes, ",")
+	checkLoadingModes("table_loading_mode", FLAGS_table_loading_mode, true)
+	checkLoadingModes("vlog_loading_mode", FLAGS_vlog_loading_mode, false)
+	tableLoadingMode = FLAGS_table_loading_mode[0]
+	vlogLoadingMode = FLAGS_vlog_loading_mode[0]
+	if FLAGS_leveldb_opt {
+		leveldbDefaultFlags()
+	}
 	FLAGS_benchmarks = strings.Split(benchmarks, ",")
+	if FLAGS_crash_child {
+		CrashChild()
+	}
+	if len(FLAGS_table_loading_mode)*len(FLAGS_vlog_loading_mode) > 1 {
+		RunLoadingModeSweep()
+		return
+	}
 	bm := MakeBenchmark()
 	bm.Run()
 }
@@ -0,0 +1,43 @@
+package main
+
+// ======================================
+//
+//	Storage engines
+//
+// ======================================
+
+const kBadgerEngine = "badger"
+
+// engineBenchmarks can run on every engine, the others need badger itself.
+// The ycsb workloads run everywhere too.
+var engineBenchmarks = map[string]bool{
+	"fillseq":           true,
+	"fillrandom":        true,
+	"overwrite":         true,
+	"fillsync":          true,
+	"fill100k":          true,
+	"deleteseq":         true,
+	"deleterandom":      true,
+	"deleteseqtxn":      true,
+	"deleterandomtxn":   true,
+	"readseq":           true,
+	"readreverse":       true,
+	"readkeys":          true,
+	"readkeysreverse":   true,
+	"readrandom":        true,
+	"readhot":           true,
+	"multireadrandom":   true,
+	"fillprefix":        true,
+	"prefixscan":        true,
+	"seekrandom":        true,
+	"seekrandomreverse": true,
+	"readwhilewriting":  true,
+	"fillttl":           true,
+	"fillttlrandom":     true,
+	"waitttl":           true,
+}
+
+func engineSupports(benchmark string) bool {
+	_, ycsb := ycsbWorkloads[benchmark]
+	return ycsb || engineBenchmarks[benchmark]
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// ============================================
+//
+//	Key choosers for skewed request distributions
+//	(ported from YCSB's ZipfianGenerator)
+//
+// ============================================
+
+const kZipfianConstant float64 = 0.99
+
+type zetaKey struct {
+	n     int64
+	theta float64
+}
+
+// zeta(n) is O(n), so it is computed once per (n, theta) and shared by all
+// threads.
+var zetaCache = struct {
+	sync.Mutex
+	m map[zetaKey]float64
+}{m: make(map[zetaKey]float64)}
+
+func zetaRange(from, to int64, theta float64) float64 {
+	sum := 0.
+	for i := from; i < to; i++ {
+		sum += 1 / math.Pow(float64(i+1), theta)
+	}
+	return sum
+}
+
+func zeta(n int64, theta float64) float64 {
+	zetaCache.Lock()
+	defer zetaCache.Unlock()
+	k := zetaKey{n, theta}
+	if z, ok := zetaCache.m[k]; ok {
+		return z
+	}
+	z := zetaRange(0, n, theta)
+	zetaCache.m[k] = z
+	return z
+}
+
+// ZipfianGenerator draws integers in [0, items) where small numbers are the
+// most popular ones. It is not safe for concurrent use.
+type ZipfianGenerator struct {
+	items     int64
+	theta     float64
+	zeta2     float64
+	alpha     float64
+	zetan     float64
+	eta       float64
+	zetaItems int64 // number of items zetan was computed for
+}
+
+func MakeZipfianGenerator(items int64, theta float64) *ZipfianGenerator {
+	z := new(ZipfianGenerator)
+	z.theta = theta
+	z.zeta2 = zetaRange(0, 2, theta)
+	z.alpha = 1.0 / (1.0 - theta)
+	z.zetan = zeta(items, theta)
+	z.zetaItems = items
+	z.setItems(items)
+	return z
+}
+
+func (z *ZipfianGenerator) setItems(items int64) {
+	if items > z.zetaItems {
+		// incrementally extend zeta when the key space grows (e.g. inserts)
+		z.zetan += zetaRange(z.zetaItems, items, z.theta)
+		z.zetaItems = items
+	} else if items < z.zetaItems {
+		z.zetan = zeta(items, z.theta)
+		z.zetaItems = items
+	}
+	z.items = items
+	z.eta = (1 - math.Pow(2.0/float64(items), 1-z.theta)) / (1 - z.zeta2/z.zetan)
+}
+
+func (z *ZipfianGenerator) Next(rd *rand.Rand) int64 {
+	return z.NextN(rd, z.items)
+}
+
+// NextN draws from [0, items), adjusting the generator if the number of
+// items has changed since the last call.
+func (z *ZipfianGenerator) NextN(rd *rand.Rand, items int64) int64 {
+	if items != z.items {
+		z.setItems(items)
+	}
+	u := rd.Float64()
+	uz := u * z.zetan
+	if uz < 1.0 {
+		return 0
+	}
+	if uz < 1.0+math.Pow(0.5, z.theta) {
+		return 1
+	}
+	ret := int64(float64(z.items) * math.Pow(z.eta*u-z.eta+1, z.alpha))
+	if ret >= z.items {
+		ret = z.items - 1
+	}
+	return ret
+}
+
+// fnvHash64 is the FNV-1a hash YCSB uses to scatter popular items.
+func fnvHash64(v int64) int64 {
+	const offset uint64 = 0xCBF29CE484222325
+	const prime uint64 = 1099511628211
+	h := offset
+	for i := 0; i < 8; i++ {
+		h ^= uint64(v & 0xff)
+		h *= prime
+		v >>= 8
+	}
+	return int64(h >> 1)
+}
+
+// ScrambledZipfian is a zipfian distribution whose popular items are spread
+// over the key space instead of being clustered at the start.
+func ScrambledZipfian(z *ZipfianGenerator, rd *rand.Rand, items int64) int64 {
+	return fnvHash64(z.NextN(rd, items)) % items
+}
+
+// SkewedLatest favors the most recently inserted of items keys.
+func SkewedLatest(z *ZipfianGenerator, rd *rand.Rand, items int64) int64 {
+	return items - 1 - z.NextN(rd, items)
+}
+
+// ============================================
+//
+//	Key distributions of --key_dist
+//
+// ============================================
+
+// key distributions
+const (
+	keyDistUniform     = "uniform"
+	keyDistZipfian     = "zipfian"
+	keyDistLatest      = "latest"
+	keyDistHotspot     = "hotspot"
+	keyDistExponential = "exponential"
+)
+
+var keyDists = map[string]bool{
+	keyDistUniform:     true,
+	keyDistZipfian:     true,
+	keyDistLatest:      true,
+	keyDistHotspot:     true,
+	keyDistExponential: true,
+}
+
+// KeyGenerator draws key indexes following a distribution. Like
+// ZipfianGenerator, it is not safe for concurrent use.
+type KeyGenerator interface {
+	// Next draws from [0, items).
+	Next(rd *rand.Rand, items int64) int64
+}
+
+// MakeKeyGenerator makes a generator which mostly draws from items keys. It
+// does the setup up front (zeta is O(items)), so that it is not timed.
+func MakeKeyGenerator(dist string, items int64) KeyGenerator {
+	switch dist {
+	case keyDistZipfian:
+		return &zipfianKeys{z: MakeZipfianGenerator(items, FLAGS_zipf_theta)}
+	case keyDistLatest:
+		return &zipfianKeys{z: MakeZipfianGenerator(items, FLAGS_zipf_theta), latest: true}
+	case keyDistHotspot:
+		return hotspotKeys{FLAGS_hotspot_set_fraction, FLAGS_hotspot_ops_fraction}
+	case keyDistExponential:
+		return exponentialKeys{FLAGS_exp_percentile, FLAGS_exp_fraction}
+	}
+	return uniformKeys{}
+}
+
+type uniformKeys struct{}
+
+func (uniformKeys) Next(rd *rand.Rand, items int64) int64 {
+	return rd.Int63n(items)
+}
+
+// zipfianKeys is a scrambled zipfian, or a zipfian skewed toward the last
+// keys if latest is set.
+type zipfianKeys struct {
+	z      *ZipfianGenerator
+	latest bool
+}
+
+func (k *zipfianKeys) Next(rd *rand.Rand, items int64) int64 {
+	if k.latest {
+		return SkewedLatest(k.z, rd, items)
+	}
+	return ScrambledZipfian(k.z, rd, items)
+}
+
+// hotspotKeys sends opsFraction of the draws to the first setFraction of the
+// keys, uniformly within each part (YCSB's HotspotIntegerGenerator).
+type hotspotKeys struct {
+	setFraction float64
+	opsFraction float64
+}
+
+func (k hotspotKeys) Next(rd *rand.Rand, items int64) int64 {
+	hot := int64(float64(items) * k.setFraction)
+	if hot < 1 {
+		hot = 1
+	}
+	if hot >= items || rd.Float64() < k.opsFraction {
+		return rd.Int63n(hot)
+	}
+	return hot + rd.Int63n(items-hot)
+}
+
+// exponentialKeys favors the first keys: percentile percent of the draws
+// fall in the first fraction of the keys (YCSB's ExponentialGenerator).
+type exponentialKeys struct {
+	percentile float64
+	fraction   float64
+}
+
+func (k exponentialKeys) Next(rd *rand.Rand, items int64) int64 {
+	gamma := -math.Log(1-k.percentile/100) / (float64(items) * k.fraction)
+	for {
+		// 1-Float64() is in (0, 1], so the log stays finite
+		if v := int64(-math.Log(1-rd.Float64()) / gamma); v < items {
+			return v
+		}
+	}
+}
+
+// KeyDist returns --key_dist if it is set, or else the benchmark's own
+// default distribution.
+func KeyDist(defaultDist string) string {
+	if FLAGS_key_dist != "" {
+		return FLAGS_key_dist
+	}
+	return defaultDist
+}
+
+func FormatKeyDist(dist string) string {
+	switch dist {
+	case keyDistZipfian, keyDistLatest:
+		return fmt.Sprintf("%s (theta %v)", dist, FLAGS_zipf_theta)
+	case keyDistHotspot:
+		return fmt.Sprintf("%s (%v of ops on %v of keys)", dist, FLAGS_hotspot_ops_fraction, FLAGS_hotspot_set_fraction)
+	case keyDistExponential:
+		return fmt.Sprintf("%s (%v%% of ops on %v of keys)", dist, FLAGS_exp_percentile, FLAGS_exp_fraction)
+	}
+	return dist
+}
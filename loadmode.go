@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dgraph-io/badger/options"
+)
+
+// ======================================
+//
+//	Table and value log loading modes
+//
+// ======================================
+
+var loadingModes = map[string]options.FileLoadingMode{
+	"fileio": options.FileIO,
+	"mmap":   options.MemoryMap,
+	"ram":    options.LoadToRAM,
+}
+
+// Loading modes of the current run, see CreateDBOption.
+var tableLoadingMode = "mmap"
+var vlogLoadingMode = "mmap"
+
+// checkLoadingModes validates a --table_loading_mode or --vlog_loading_mode
+// list. Badger v1.6 can't load the value log to RAM.
+func checkLoadingModes(flagName string, modes []string, allowRAM bool) {
+	for _, m := range modes {
+		if _, ok := loadingModes[m]; !ok || m == "ram" && !allowRAM {
+			fmt.Fprintf(os.Stderr, "invalid %s %q\n", flagName, m)
+			os.Exit(1)
+		}
+	}
+}
+
+type benchResult struct {
+	name        string
+	microsPerOp float64
+	opsPerSec   float64
+}
+
+type loadingModeRun struct {
+	table, vlog string
+	results     []benchResult
+	peakRSS     int64 // bytes, -1 if unknown
+}
+
+// resetPeakRSS resets VmHWM of the process, so that the next peakRSS only
+// covers what happened since. Linux only.
+func resetPeakRSS() bool {
+	return os.WriteFile("/proc/self/clear_refs", []byte("5"), 0) == nil
+}
+
+func peakRSS() int64 {
+	file, err := os.Open("/proc/self/status")
+	if err != nil {
+		return -1
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == "VmHWM:" {
+			if kb, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+				return kb << 10
+			}
+		}
+	}
+	return -1
+}
+
+// RunLoadingModeSweep runs the benchmark list on a fresh db once per
+// combination of --table_loading_mode and --vlog_loading_mode, then prints
+// their ops/sec side by side.
+func RunLoadingModeSweep() {
+	var runs []*loadingModeRun
+	peakReset := true
+	for _, table := range FLAGS_table_loading_mode {
+		for _, vlog := range FLAGS_vlog_loading_mode {
+			tableLoadingMode, vlogLoadingMode = table, vlog
+			run := &loadingModeRun{table: table, vlog: vlog}
+			peakReset = resetPeakRSS() && peakReset
+			bm := MakeBenchmark()
+			bm.Run()
+			run.peakRSS = peakRSS()
+			run.results = bm.results
+			bm.ReleaseDBHandles()
+			if err := bm.engine.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to close db: %s\n", err.Error())
+				os.Exit(1)
+			}
+			runs = append(runs, run)
+			fmt.Fprintf(os.Stdout, "\n")
+		}
+	}
+	PrintLoadingModeSummary(runs, peakReset)
+}
+
+func PrintLoadingModeSummary(runs []*loadingModeRun, peakReset bool) {
+	fmt.Fprintf(os.Stdout, "Loading modes (ops/sec, tables/vlog):\n")
+	fmt.Fprintf(os.Stdout, "%-18s", "")
+	for _, run := range runs {
+		fmt.Fprintf(os.Stdout, " %14s", run.table+"/"+run.vlog)
+	}
+	fmt.Fprintf(os.Stdout, "\n")
+	// every run reports the same benchmarks in the same order
+	for i, r := range runs[0].results {
+		fmt.Fprintf(os.Stdout, "%-18s", r.name)
+		for _, run := range runs {
+			if i < len(run.results) {
+				fmt.Fprintf(os.Stdout, " %14.0f", run.results[i].opsPerSec)
+			} else {
+				fmt.Fprintf(os.Stdout, " %14s", "-")
+			}
+		}
+		fmt.Fprintf(os.Stdout, "\n")
+	}
+	rssName := "peak RSS (MB)"
+	if !peakReset {
+		// VmHWM could not be reset, each run includes the ones before it
+		rssName = "peak RSS (MB)*"
+	}
+	fmt.Fprintf(os.Stdout, "%-18s", rssName)
+	for _, run := range runs {
+		if run.peakRSS < 0 {
+			fmt.Fprintf(os.Stdout, " %14s", "-")
+		} else {
+			fmt.Fprintf(os.Stdout, " %14.1f", float64(run.peakRSS)/1048576.0)
+		}
+	}
+	fmt.Fprintf(os.Stdout, "\n")
+	if !peakReset {
+		fmt.Fprintf(os.Stdout, "* cumulative, /proc/self/clear_refs is not writable\n")
+	}
+	FFlush(os.Stdout)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dgraph-io/badger"
+)
+
+// ======================================
+//
+//	Managed mode (user timestamp) MVCC
+//
+// ======================================
+
+// Benchmarks that can run on a db opened with badger.OpenManaged. The
+// managed-only ones refuse to run without --managed.
+var managedBenchmarks = map[string]bool{
+	"fillversions":      true,
+	"readold":           true,
+	"readlatest":        true,
+	"readseq":           true,
+	"readreverse":       true,
+	"readkeys":          true,
+	"readkeysreverse":   true,
+	"readrandom":        true,
+	"readhot":           true,
+	"multireadrandom":   true,
+	"seekrandom":        true,
+	"seekrandomreverse": true,
+	"prefixscan":        true,
+	"compact":           true,
+	"open":              true,
+}
+
+var managedOnlyBenchmarks = map[string]bool{
+	"fillversions": true,
+	"readold":      true,
+	"readlatest":   true,
+}
+
+// Version values start with the version they were written at.
+func versionValue(value []byte, version uint64) []byte {
+	v := append([]byte{}, value...)
+	copy(v, fmt.Sprintf("%08d", version))
+	return v
+}
+
+func (bm *Benchmark) FillVersions(thread *ThreadState) {
+	var bytes int64 = 0
+	rnd := thread.NewRand("values")
+	values := NewValueGenerator(rnd, bm.valueSize)
+	for v := 1; v <= FLAGS_versions; v++ {
+		ts := uint64(v)
+		wb := bm.db.NewWriteBatchAt(ts)
+		for i := 0; i < bm.num; i++ {
+			key := GenKey(i)
+			value := versionValue(values.Next(), ts)
+			if err := wb.SetEntry(badger.NewEntry([]byte(key), value)); err != nil {
+				fmt.Fprintf(os.Stderr, "put error: %s\n", err.Error())
+				os.Exit(1)
+			}
+			bytes += int64(len(value)) + int64(len(key))
+			thread.stats.FinishedSingleOp()
+		}
+		if err := wb.Flush(); err != nil {
+			fmt.Fprintf(os.Stderr, "put errror: %s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+	thread.stats.AddBytes(bytes)
+	if thread.tid == 0 {
+		bm.latestTs = uint64(FLAGS_versions)
+		if FLAGS_discard_ts > 0 {
+			bm.db.SetDiscardTs(FLAGS_discard_ts)
+		}
+	}
+	msg := fmt.Sprintf("(%d keys, %d versions each)", bm.num, FLAGS_versions)
+	thread.stats.AddMsg(msg)
+}
+
+// doReadAt reads random keys at the timestamps picked by readTs and counts
+// how many reads saw exactly the version they asked for.
+func (bm *Benchmark) doReadAt(thread *ThreadState, readTs func() uint64) {
+	if bm.latestTs == 0 {
+		fmt.Fprintf(os.Stderr, "no versions written, run fillversions first\n")
+		os.Exit(1)
+	}
+	found := 0
+	exact := 0
+	for i := 0; i < bm.reads; i++ {
+		k := thread.NextKey(FLAGS_num)
+		ts := readTs()
+		_, version, err := bm.db.GetAt(GenKey(k), ts)
+		if err == nil {
+			found++
+			if version == ts {
+				exact++
+			}
+		} else if err != badger.ErrKeyNotFound {
+			fmt.Fprintf(os.Stderr, "read error: %s\n", err.Error())
+			os.Exit(1)
+		}
+		thread.stats.FinishedSingleOp()
+	}
+	msg := fmt.Sprintf("(%d of %d found, %d at the requested version)", found, bm.reads, exact)
+	thread.stats.AddMsg(msg)
+}
+
+func (bm *Benchmark) ReadOldVersion(thread *ThreadState) {
+	bm.doReadAt(thread, func() uint64 {
+		if bm.latestTs < 2 {
+			return bm.latestTs
+		}
+		return 1 + uint64(thread.rd.Int63n(int64(bm.latestTs-1)))
+	})
+}
+
+func (bm *Benchmark) ReadLatestVersion(thread *ThreadState) {
+	bm.doReadAt(thread, func() uint64 {
+		return bm.latestTs
+	})
+}
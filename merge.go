@@ -0,0 +1,146 @@
+package main
+
+import (
+	"badgerBench/bDB"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"github.com/dgraph-io/badger"
+)
+
+// ======================================
+//
+//	Merge operator and counter workloads
+//
+// ======================================
+
+func MergeKey(k int) string {
+	return "merge-" + GenKey(k)
+}
+
+func CounterKey(k int) string {
+	return "counter-" + GenKey(k)
+}
+
+// allocCounters sets up the tallies mergeverify checks the counters against.
+func (bm *Benchmark) allocCounters() {
+	if bm.mergeAdds == nil {
+		bm.mergeAdds = make([]int64, FLAGS_merge_keys)
+		bm.counterAdds = make([]int64, FLAGS_merge_keys)
+	}
+}
+
+// OpenMergeOperators starts one merge operator per merge key unless they are
+// already running. Run stops them again once the benchmark is done, so that
+// their compactions don't load the benchmarks after it.
+func (bm *Benchmark) OpenMergeOperators() {
+	bm.allocCounters()
+	if bm.mergeOps != nil {
+		return
+	}
+	f := bDB.MergeFuncs[FLAGS_merge_func]
+	bm.mergeOps = make([]*badger.MergeOperator, FLAGS_merge_keys)
+	for k := range bm.mergeOps {
+		bm.mergeOps[k] = bm.db.GetMergeOperator(MergeKey(k), f, FLAGS_merge_interval)
+	}
+}
+
+func (bm *Benchmark) StopMergeOperators() {
+	for _, op := range bm.mergeOps {
+		op.Stop()
+	}
+	bm.mergeOps = nil
+}
+
+func (bm *Benchmark) MergeRandom(thread *ThreadState) {
+	var bytes int64 = 0
+	operand := make([]byte, 8)
+	binary.BigEndian.PutUint64(operand, 1)
+	for i := 0; i < bm.num; i++ {
+		k := thread.NextKey(FLAGS_merge_keys)
+		if err := bm.mergeOps[k].Add(operand); err != nil {
+			fmt.Fprintf(os.Stderr, "merge error: %s\n", err.Error())
+			os.Exit(1)
+		}
+		atomic.AddInt64(&bm.mergeAdds[k], 1)
+		bytes += int64(len(operand) + len(MergeKey(k)))
+		thread.stats.FinishedSingleOp()
+	}
+	thread.stats.AddBytes(bytes)
+	msg := fmt.Sprintf("(%s over %d keys)", FLAGS_merge_func, FLAGS_merge_keys)
+	thread.stats.AddMsg(msg)
+}
+
+// CounterTxn increments the same kind of counters as MergeRandom, but
+// through read-modify-write transactions.
+func (bm *Benchmark) CounterTxn(thread *ThreadState) {
+	var conflicts int64
+	for i := 0; i < bm.num; i++ {
+		k := thread.NextKey(FLAGS_merge_keys)
+		key := []byte(CounterKey(k))
+		for {
+			err := bm.db.DoUpdate(func(txn *badger.Txn) error {
+				return incrementCounter(txn, key)
+			})
+			if err == nil {
+				break
+			}
+			if err != badger.ErrConflict {
+				fmt.Fprintf(os.Stderr, "countertxn error: %s\n", err.Error())
+				os.Exit(1)
+			}
+			conflicts++
+		}
+		atomic.AddInt64(&bm.counterAdds[k], 1)
+		thread.stats.FinishedSingleOp()
+	}
+	thread.stats.AddCounter("conflicts", conflicts)
+	thread.stats.SetSummary(func(s *Stats) string {
+		return fmt.Sprintf("(%d keys, %d conflicts retried)", FLAGS_merge_keys, s.counters["conflicts"])
+	})
+}
+
+// counterValue decodes a counter written by the given merge function.
+func counterValue(mergeFunc string, v []byte) int64 {
+	if mergeFunc == "append" {
+		return int64(len(v) / 8)
+	}
+	if len(v) != 8 {
+		return -1
+	}
+	return int64(binary.BigEndian.Uint64(v))
+}
+
+// MergeVerify reads the merge keys without merge operators, whose
+// compactions would otherwise run during the check.
+func (bm *Benchmark) MergeVerify(thread *ThreadState) {
+	f := bDB.MergeFuncs[FLAGS_merge_func]
+	mismatches := 0
+	for k := 0; k < FLAGS_merge_keys; k++ {
+		v, err := bm.db.GetMerged(MergeKey(k), f)
+		if err != nil && err != bDB.ErrNotFound {
+			fmt.Fprintf(os.Stderr, "failed to read merge key: %s\n", err.Error())
+			os.Exit(1)
+		}
+		if got := counterValue(FLAGS_merge_func, v); err == nil && got != bm.mergeAdds[k] ||
+			err == bDB.ErrNotFound && bm.mergeAdds[k] != 0 {
+			mismatches++
+		}
+		thread.stats.FinishedSingleOp()
+
+		s, err := bm.db.Get(CounterKey(k))
+		if err != nil && err != bDB.ErrNotFound {
+			fmt.Fprintf(os.Stderr, "failed to read counter key: %s\n", err.Error())
+			os.Exit(1)
+		}
+		if got := counterValue("add", []byte(s)); err == nil && got != bm.counterAdds[k] ||
+			err == bDB.ErrNotFound && bm.counterAdds[k] != 0 {
+			mismatches++
+		}
+		thread.stats.FinishedSingleOp()
+	}
+	msg := fmt.Sprintf("(%d counters checked, %d mismatches)", 2*FLAGS_merge_keys, mismatches)
+	thread.stats.AddMsg(msg)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"badgerBench/bDB"
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// ======================================
+//
+//	DB open and crash recovery
+//
+// ======================================
+
+// Printed by the opencrash child once the db is open.
+const kCrashChildReady = "crash child ready"
+
+func formatReplay(d *bDB.BadgerDBWrapper) string {
+	bytes, took := d.LastReplay()
+	return fmt.Sprintf("replayed %.1f MB of vlog in %.3f s", float64(bytes)/1048576.0, took.Seconds())
+}
+
+// CloseDB closes the db, which open and opencrash then time reopening. It is
+// called by Run before the benchmark, so that closing is not timed.
+func (bm *Benchmark) CloseDB() {
+	if err := bm.db.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to close db: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func (bm *Benchmark) OpenClean(thread *ThreadState) {
+	start := time.Now()
+	bm.Open(CreateDBOption())
+	elapsed := time.Since(start)
+	thread.stats.FinishedSingleOp()
+	msg := fmt.Sprintf("(clean shutdown, %.3f s, %s)", elapsed.Seconds(), formatReplay(bm.db))
+	thread.stats.AddMsg(msg)
+}
+
+// CrashChild opens the db and writes random keys until it gets killed.
+func CrashChild() {
+	FLAGS_use_existing_db = true
+	bm := MakeBenchmark()
+	bm.Open(CreateDBOption())
+	fmt.Fprintln(os.Stdout, kCrashChildReady)
+	rnd := rand.New(rand.NewSource(StreamSeed("opencrash", 0, "child")))
+	values := NewValueGenerator(rnd, bm.valueSize)
+	w := bm.NewBatchWriter(WriteMode(writeModeBatchN))
+	for {
+		key := GenKey(rnd.Intn(FLAGS_num))
+		if err := w.Set([]byte(key), values.Next()); err != nil {
+			fmt.Fprintf(os.Stderr, "put errror: %s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+}
+
+// runCrashChild starts a child writing to the db and kills it after
+// --crash_after.
+func runCrashChild() error {
+	args := append([]string{}, os.Args[1:]...)
+	args = append(args, "-crash_child")
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(stdout)
+	ready := false
+	for !ready && scanner.Scan() {
+		ready = scanner.Text() == kCrashChildReady
+	}
+	if !ready {
+		cmd.Wait()
+		return fmt.Errorf("child exited before opening the db")
+	}
+	time.Sleep(FLAGS_crash_after)
+	if err = cmd.Process.Kill(); err != nil {
+		return err
+	}
+	cmd.Wait()
+	return nil
+}
+
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0777)
+		}
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		_, err = io.Copy(out, in)
+		return err
+	})
+}
+
+// The copy of the crashed db opened without truncating it.
+func crashCopyDir() string {
+	return FLAGS_db + ".crash"
+}
+
+// CrashDB kills a child writing to the closed db after --crash_after and
+// copies the crashed db, so that OpenCrash can open both the same way. Like
+// CloseDB, it is called by Run before the benchmark.
+func (bm *Benchmark) CrashDB() {
+	if err := runCrashChild(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to run crash child: %s\n", err.Error())
+		os.Exit(1)
+	}
+	os.RemoveAll(crashCopyDir())
+	if err := copyDir(FLAGS_db, crashCopyDir()); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to copy crashed db: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func (bm *Benchmark) OpenCrash(thread *ThreadState) {
+	opt := CreateDBOption()
+	opt.Dir = crashCopyDir()
+	opt.ValueDir = crashCopyDir()
+	opt.Truncate = false
+	noTruncate := bDB.MakeDB()
+	start := time.Now()
+	err := noTruncate.Open(opt)
+	elapsed := time.Since(start)
+	thread.stats.FinishedSingleOp()
+	var noTruncateMsg string
+	if err != nil {
+		noTruncateMsg = fmt.Sprintf("without truncate failed after %.3f s: %s", elapsed.Seconds(), err.Error())
+	} else {
+		noTruncateMsg = fmt.Sprintf("without truncate %.3f s, %s", elapsed.Seconds(), formatReplay(noTruncate))
+	}
+
+	opt = CreateDBOption()
+	opt.Truncate = true
+	start = time.Now()
+	bm.Open(opt)
+	elapsed = time.Since(start)
+	thread.stats.FinishedSingleOp()
+	thread.stats.Stop()
+	if err == nil {
+		noTruncate.Close()
+	}
+	os.RemoveAll(crashCopyDir())
+	msg := fmt.Sprintf("(killed after %s; %s; with truncate %.3f s, %s)",
+		FLAGS_crash_after, noTruncateMsg, elapsed.Seconds(), formatReplay(bm.db))
+	thread.stats.AddMsg(msg)
+}
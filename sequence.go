@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dgraph-io/badger"
+)
+
+// ======================================
+//
+//	ID allocation with badger.Sequence
+//
+// ======================================
+
+const kSharedSequenceKey = "sequence-shared"
+
+func (bm *Benchmark) OpenSharedSequence() {
+	if bm.sequence != nil {
+		return
+	}
+	var err error
+	if bm.sequence, err = bm.db.GetSequence(kSharedSequenceKey, FLAGS_seq_bandwidth); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to get sequence: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func (bm *Benchmark) ReleaseSharedSequence() {
+	if bm.sequence == nil {
+		return
+	}
+	if err := bm.sequence.Release(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to release sequence: %s\n", err.Error())
+		os.Exit(1)
+	}
+	bm.sequence = nil
+}
+
+func (bm *Benchmark) doSequence(thread *ThreadState, seq *badger.Sequence) {
+	for i := 0; i < bm.num; i++ {
+		if _, err := seq.Next(); err != nil {
+			fmt.Fprintf(os.Stderr, "sequence error: %s\n", err.Error())
+			os.Exit(1)
+		}
+		thread.stats.FinishedSingleOp()
+	}
+	msg := fmt.Sprintf("(bandwidth %d)", FLAGS_seq_bandwidth)
+	thread.stats.AddMsg(msg)
+	thread.stats.SetSummary(func(s *Stats) string {
+		return fmt.Sprintf("(%.0f allocations/sec)", s.OpsPerSec())
+	})
+}
+
+func (bm *Benchmark) SequenceShared(thread *ThreadState) {
+	bm.doSequence(thread, bm.sequence)
+}
+
+func (bm *Benchmark) SequencePerThread(thread *ThreadState) {
+	key := fmt.Sprintf("sequence-thread-%d", thread.tid)
+	seq, err := bm.db.GetSequence(key, FLAGS_seq_bandwidth)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to get sequence: %s\n", err.Error())
+		os.Exit(1)
+	}
+	bm.doSequence(thread, seq)
+	if err := seq.Release(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to release sequence: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+// lostAcrossReopen takes one ID from the sequence at key, reopens the db,
+// releasing the sequence first if release is set, and returns how many IDs
+// were skipped by the first Next() after the reopen.
+func (bm *Benchmark) lostAcrossReopen(key string, release bool) uint64 {
+	check := func(err error) {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sequencereopen error: %s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+	seq, err := bm.db.GetSequence(key, FLAGS_seq_bandwidth)
+	check(err)
+	last, err := seq.Next()
+	check(err)
+	if release {
+		check(seq.Release())
+	}
+	check(bm.db.Reopen())
+	seq, err = bm.db.GetSequence(key, FLAGS_seq_bandwidth)
+	check(err)
+	first, err := seq.Next()
+	check(err)
+	check(seq.Release())
+	return first - last - 1
+}
+
+func (bm *Benchmark) SequenceReopen(thread *ThreadState) {
+	released := bm.lostAcrossReopen("sequence-reopen-released", true)
+	thread.stats.FinishedSingleOp()
+	unreleased := bm.lostAcrossReopen("sequence-reopen-unreleased", false)
+	thread.stats.FinishedSingleOp()
+	msg := fmt.Sprintf("(bandwidth %d, %d IDs lost with Release, %d without)",
+		FLAGS_seq_bandwidth, released, unreleased)
+	thread.stats.AddMsg(msg)
+}
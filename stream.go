@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dgraph-io/badger/pb"
+)
+
+// ======================================
+//
+//	Stream framework scan
+//
+// ======================================
+
+// Max number of key range boundaries printed by the stream benchmark
+const kMaxPrintedSplits = 8
+
+// streamSplits returns the boundaries the stream actually splits its key
+// ranges at: like Stream.produceRanges, it keeps only every
+// len(splits)/numGo-th key split, so that there are about numGo ranges.
+func streamSplits(splits []string, numGo int) []string {
+	pickEvery := len(splits) / numGo
+	if pickEvery < 1 {
+		pickEvery = 1
+	}
+	var picked []string
+	for i, split := range splits {
+		if (i+1)%pickEvery == 0 {
+			picked = append(picked, split)
+		}
+	}
+	return picked
+}
+
+func (bm *Benchmark) StreamScan(thread *ThreadState) {
+	prefix := []byte(FLAGS_stream_prefix)
+	splits := streamSplits(bm.db.KeySplits(prefix), FLAGS_stream_num_go)
+
+	var bytes int64 = 0
+	stream := bm.db.NewStream()
+	stream.NumGo = FLAGS_stream_num_go
+	stream.Prefix = prefix
+	stream.LogPrefix = "Bench.Stream"
+	// Send is always called from a single goroutine
+	stream.Send = func(list *pb.KVList) error {
+		for _, kv := range list.Kv {
+			bytes += int64(len(kv.Key) + len(kv.Value))
+			thread.stats.FinishedSingleOp()
+		}
+		return nil
+	}
+	if err := stream.Orchestrate(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to stream: %s\n", err.Error())
+		os.Exit(1)
+	}
+	thread.stats.AddBytes(bytes)
+
+	printed := splits
+	if len(printed) > kMaxPrintedSplits {
+		printed = printed[:kMaxPrintedSplits]
+	}
+	bounds := strings.Join(printed, ", ")
+	if len(splits) > len(printed) {
+		bounds += ", ..."
+	}
+	msg := fmt.Sprintf("(%d goroutines, %d key ranges split at [%s])",
+		FLAGS_stream_num_go, len(splits)+1, bounds)
+	thread.stats.AddMsg(msg)
+	thread.stats.SetSummary(func(s *Stats) string {
+		return fmt.Sprintf("(%.0f keys/sec)", s.OpsPerSec())
+	})
+}
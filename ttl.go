@@ -0,0 +1,97 @@
+package main
+
+import (
+	"badgerBench/bDB"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ======================================
+//
+//	TTL workloads
+//
+// ======================================
+
+func (bm *Benchmark) WriteSeqTTL(thread *ThreadState) {
+	bm.doWrite(thread, true, FLAGS_ttl)
+	bm.setTTLDeadline(thread)
+}
+
+func (bm *Benchmark) WriteRandomTTL(thread *ThreadState) {
+	bm.doWrite(thread, false, FLAGS_ttl)
+	bm.setTTLDeadline(thread)
+}
+
+func (bm *Benchmark) setTTLDeadline(thread *ThreadState) {
+	thread.stats.AddMsg(fmt.Sprintf("(ttl %s)", FLAGS_ttl))
+	if thread.tid == 0 {
+		// badger keeps expiry in whole seconds, allow for the rounding
+		bm.ttlDeadline = time.Now().Add(FLAGS_ttl + time.Second)
+	}
+}
+
+func (bm *Benchmark) WaitTTL(thread *ThreadState) {
+	wait := time.Until(bm.ttlDeadline)
+	if wait > 0 {
+		time.Sleep(wait)
+	} else {
+		wait = 0
+	}
+	thread.stats.FinishedSingleOp()
+	thread.stats.AddMsg(fmt.Sprintf("(waited %.3f s)", wait.Seconds()))
+}
+
+func (bm *Benchmark) ReadTTL(thread *ThreadState) {
+	found := 0
+	var missed []string
+	for i := 0; i < bm.reads; i++ {
+		k := thread.NextKey(FLAGS_num)
+		key := GenKey(k)
+		if _, err := bm.db.Get(key); err == nil {
+			found++
+		} else {
+			missed = append(missed, key)
+		}
+		thread.stats.FinishedSingleOp()
+	}
+	thread.stats.Stop()
+	thread.stats.AddCounter("reads", int64(bm.reads))
+	thread.stats.AddCounter("found", int64(found))
+	thread.stats.AddCounter("expired", int64(bm.countMisses(missed, bDB.MissExpired)))
+	thread.stats.SetSummary(func(s *Stats) string {
+		return fmt.Sprintf("(%d of %d found, %d expired)", s.counters["found"], s.counters["reads"], s.counters["expired"])
+	})
+}
+
+// Reclaim compacts the LSM tree and then runs value log GC until there is
+// nothing left to rewrite, reporting how much space came back.
+func (bm *Benchmark) Reclaim(thread *ThreadState) {
+	lsmBefore, vlogBefore, err := bm.db.Size()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to get db size: %s\n", err.Error())
+		os.Exit(1)
+	}
+	start := time.Now()
+	if err := bm.db.Compact(CreateDBOption().NumCompactors); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to compact: %s\n", err.Error())
+		os.Exit(1)
+	}
+	compactTime := time.Since(start)
+	rewrites := 0
+	for bm.db.VlogGC(FLAGS_gc_discard_ratio) == nil {
+		rewrites++
+	}
+	elapsed := time.Since(start)
+	thread.stats.FinishedSingleOp()
+	lsmAfter, vlogAfter, err := bm.db.Size()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to get db size: %s\n", err.Error())
+		os.Exit(1)
+	}
+	reclaimed := lsmBefore + vlogBefore - lsmAfter - vlogAfter
+	msg := fmt.Sprintf("(%.3f s compaction, %.3f s total, %d vlog files rewritten, %s, %.1f MB reclaimed)",
+		compactTime.Seconds(), elapsed.Seconds(), rewrites,
+		FormatSizeChange(lsmBefore, vlogBefore, lsmAfter, vlogAfter), float64(reclaimed)/1048576.0)
+	thread.stats.AddMsg(msg)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+
+	"github.com/dgraph-io/badger"
+)
+
+// ======================================
+//
+//	Transactional workloads
+//
+// ======================================
+
+// TxnKey names the counters of txnrmw, apart from the keys of the dataset.
+func TxnKey(k int) string {
+	return "txn-" + GenKey(k)
+}
+
+// incrementCounter does a get-then-set of an 8-byte counter in txn.
+func incrementCounter(txn *badger.Txn, key []byte) error {
+	var counter uint64
+	item, err := txn.Get(key)
+	if err == nil {
+		err = item.Value(func(v []byte) error {
+			if len(v) == 8 {
+				counter = binary.BigEndian.Uint64(v)
+			}
+			return nil
+		})
+	}
+	if err != nil && err != badger.ErrKeyNotFound {
+		return err
+	}
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, counter+1)
+	return txn.Set(key, buf)
+}
+
+func (bm *Benchmark) TxnReadModifyWrite(thread *ThreadState) {
+	var commits, conflicts, retries, aborts int64
+	for i := 0; i < bm.reads; i++ {
+		key := []byte(TxnKey(thread.NextKey(FLAGS_txn_hot_keys)))
+		for attempt := 0; ; attempt++ {
+			txn := bm.db.NewTransaction(true)
+			err := incrementCounter(txn, key)
+			if err == nil {
+				err = txn.Commit()
+			}
+			txn.Discard()
+			if err == nil {
+				commits++
+				break
+			}
+			if err != badger.ErrConflict {
+				fmt.Fprintf(os.Stderr, "txnrmw error: %s\n", err.Error())
+				os.Exit(1)
+			}
+			conflicts++
+			if attempt >= FLAGS_txn_retries {
+				aborts++
+				break
+			}
+			retries++
+		}
+		thread.stats.FinishedSingleOp()
+	}
+	thread.stats.AddCounter("commits", commits)
+	thread.stats.AddCounter("conflicts", conflicts)
+	thread.stats.AddCounter("retries", retries)
+	thread.stats.AddCounter("aborts", aborts)
+	thread.stats.SetSummary(func(s *Stats) string {
+		commits := s.counters["commits"]
+		conflicts := s.counters["conflicts"]
+		rate := 0.
+		if attempts := commits + conflicts; attempts > 0 {
+			rate = float64(conflicts) / float64(attempts) * 100
+		}
+		goodput := 0.
+		if elapsed := (s.finish - s.start) * 1e-6; elapsed > 0 {
+			goodput = float64(commits) / elapsed
+		}
+		return fmt.Sprintf("(%d committed, %.0f commits/sec, %d conflicts, %.2f%% conflict rate, %d retries, %d aborted)",
+			commits, goodput, conflicts, rate, s.counters["retries"], s.counters["aborts"])
+	})
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+)
+
+// ======================================
+//
+//	Value generator
+//	(like leveldb's RandomGenerator)
+//
+// ======================================
+
+// value size distributions
+const (
+	valueDistFixed   = "fixed"   // always --value_size
+	valueDistUniform = "uniform" // uniform in [min, max]
+	valueDistNormal  = "normal"  // around --value_size, stddev (max-min)/6
+	valueDistZipf    = "zipf"    // min is the most popular size, then min+1...
+)
+
+var valueDists = map[string]bool{
+	valueDistFixed:   true,
+	valueDistUniform: true,
+	valueDistNormal:  true,
+	valueDistZipf:    true,
+}
+
+// Values are slices of a buffer of at least this size.
+const kValueBufferSize = 1 << 20
+
+// Length of the pieces of the buffer compressed as a whole
+const kCompressiblePieceLen = 100
+
+// compressibleBytes returns n bytes which compress to about ratio*n: a random
+// run of that length, repeated (leveldb's test::CompressibleString).
+func compressibleBytes(rnd *rand.Rand, ratio float64, n int) []byte {
+	raw := int(float64(n) * ratio)
+	if raw < 1 {
+		raw = 1
+	}
+	run := []byte(RandomString(rnd, raw))
+	buf := make([]byte, 0, n)
+	for len(buf) < n {
+		buf = append(buf, run...)
+	}
+	return buf[:n]
+}
+
+// ValueGenerator hands out a different value on every call, as slices of a
+// buffer filled once, so that it does not allocate per op. The buffer is
+// never written again, so the values can be kept by write batches until they
+// are committed. It is not safe for concurrent use.
+type ValueGenerator struct {
+	data     []byte
+	pos      int
+	rd       *rand.Rand // draws the sizes
+	dist     string
+	size     int // --value_size
+	min, max int
+	zipf     *ZipfianGenerator
+}
+
+// NewValueGenerator makes values around size bytes long, following
+// --value_size_dist.
+func NewValueGenerator(rnd *rand.Rand, size int) *ValueGenerator {
+	g := new(ValueGenerator)
+	g.rd = rnd
+	g.dist = FLAGS_value_size_dist
+	g.size = size
+	g.min, g.max = ValueSizeBounds(size)
+	if g.dist == valueDistFixed {
+		g.min, g.max = size, size
+	}
+	if g.min < 0 || g.max < g.min {
+		fmt.Fprintf(os.Stderr, "invalid value size bounds [%d, %d] for %d byte values\n", g.min, g.max, size)
+		os.Exit(1)
+	}
+	bufSize := kValueBufferSize
+	if bufSize < 2*g.max {
+		bufSize = 2 * g.max
+	}
+	g.data = make([]byte, 0, bufSize+kCompressiblePieceLen)
+	for len(g.data) < bufSize {
+		g.data = append(g.data, compressibleBytes(rnd, FLAGS_compression_ratio, kCompressiblePieceLen)...)
+	}
+	return g
+}
+
+// ValueSizeBounds returns --value_size_min and --value_size_max, which
+// default to half and twice the mean size. Benchmarks with values of their
+// own size (fill100k) get the bounds scaled by size/--value_size.
+func ValueSizeBounds(size int) (min, max int) {
+	min, max = FLAGS_value_size_min, FLAGS_value_size_max
+	if size != FLAGS_value_size && FLAGS_value_size > 0 {
+		min = int(int64(min) * int64(size) / int64(FLAGS_value_size))
+		max = int(int64(max) * int64(size) / int64(FLAGS_value_size))
+	}
+	if min < 0 {
+		min = size / 2
+	}
+	if max < 0 {
+		max = 2 * size
+	}
+	return min, max
+}
+
+// MeanValueSize returns the expected size of the values made for size by
+// --value_size_dist, which is size itself only for some distributions.
+func MeanValueSize(size int) float64 {
+	min, max := ValueSizeBounds(size)
+	switch FLAGS_value_size_dist {
+	case valueDistUniform:
+		return float64(min+max) / 2
+	case valueDistNormal:
+		// mean of the normal distribution clamped to [min, max]
+		mu, sigma := float64(size), float64(max-min)/6
+		if sigma == 0 {
+			return math.Max(float64(min), math.Min(float64(max), mu))
+		}
+		cdf := func(x float64) float64 { return (1 + math.Erf(x/math.Sqrt2)) / 2 }
+		pdf := func(x float64) float64 { return math.Exp(-x*x/2) / math.Sqrt(2*math.Pi) }
+		a, b := (float64(min)-mu)/sigma, (float64(max)-mu)/sigma
+		return float64(min)*cdf(a) + float64(max)*(1-cdf(b)) +
+			mu*(cdf(b)-cdf(a)) + sigma*(pdf(a)-pdf(b))
+	case valueDistZipf:
+		var sum, zetan float64
+		for i := 0; i <= max-min; i++ {
+			p := 1 / math.Pow(float64(i+1), kZipfianConstant)
+			sum += float64(i) * p
+			zetan += p
+		}
+		return float64(min) + sum/zetan
+	}
+	return float64(size)
+}
+
+func (g *ValueGenerator) nextSize() int {
+	var n int
+	switch g.dist {
+	case valueDistFixed:
+		return g.size
+	case valueDistUniform:
+		n = g.min + g.rd.Intn(g.max-g.min+1)
+	case valueDistNormal:
+		n = int(math.Round(g.rd.NormFloat64()*float64(g.max-g.min)/6 + float64(g.size)))
+	case valueDistZipf:
+		if g.zipf == nil {
+			g.zipf = MakeZipfianGenerator(int64(g.max-g.min+1), kZipfianConstant)
+		}
+		n = g.min + int(g.zipf.Next(g.rd))
+	}
+	if n < g.min {
+		n = g.min
+	} else if n > g.max {
+		n = g.max
+	}
+	return n
+}
+
+// Generate returns the next n bytes of the buffer.
+func (g *ValueGenerator) Generate(n int) []byte {
+	if g.pos+n > len(g.data) {
+		g.pos = 0
+	}
+	g.pos += n
+	return g.data[g.pos-n : g.pos : g.pos]
+}
+
+// Next returns a value whose size follows the size distribution.
+func (g *ValueGenerator) Next() []byte {
+	return g.Generate(g.nextSize())
+}
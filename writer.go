@@ -0,0 +1,117 @@
+package main
+
+import (
+	"badgerBench/bDB"
+	"fmt"
+	"time"
+)
+
+// ======================================
+//
+//	Write path modes
+//
+// ======================================
+
+// write modes
+const (
+	writeModeBatch  = "batch"  // one WriteBatch shared by the whole run
+	writeModeBatchN = "batchn" // a WriteBatch per entriesPerBatch entries
+	writeModeTxn    = "txn"    // a db.Update transaction per entriesPerBatch entries
+	writeModeOp     = "op"     // a db.Update transaction per entry
+)
+
+var writeModes = map[string]bool{
+	writeModeBatch:  true,
+	writeModeBatchN: true,
+	writeModeTxn:    true,
+	writeModeOp:     true,
+}
+
+// BatchWriter applies writes to the engine according to a write mode. Flush
+// must be called once all entries are written.
+type BatchWriter struct {
+	mode      string
+	batchSize int
+	batch     bDB.Batch
+	pending   int // writes since the last commit
+}
+
+// WriteMode returns --write_mode if it is set, or else the benchmark's own
+// default mode.
+func WriteMode(defaultMode string) string {
+	if FLAGS_write_mode != "" {
+		return FLAGS_write_mode
+	}
+	return defaultMode
+}
+
+func (bm *Benchmark) NewBatchWriter(mode string) *BatchWriter {
+	w := new(BatchWriter)
+	w.mode = mode
+	w.batchSize = bm.entriesPerBatch
+	if w.batchSize < 1 {
+		w.batchSize = 1
+	}
+	// batch and batchn go through WriteBatches, txn and op through
+	// transactions
+	w.batch = bm.engine.NewBatch(mode == writeModeTxn || mode == writeModeOp)
+	return w
+}
+
+func (w *BatchWriter) String() string {
+	switch w.mode {
+	case writeModeBatchN, writeModeTxn:
+		return fmt.Sprintf("(mode %s, %d per batch)", w.mode, w.batchSize)
+	}
+	return fmt.Sprintf("(mode %s)", w.mode)
+}
+
+func (w *BatchWriter) Set(key, value []byte) error {
+	return w.SetTTL(key, value, 0)
+}
+
+// SetTTL writes a key which expires after ttl.
+func (w *BatchWriter) SetTTL(key, value []byte, ttl time.Duration) error {
+	if err := w.batch.Set(key, value, ttl); err != nil {
+		return err
+	}
+	return w.added()
+}
+
+func (w *BatchWriter) Delete(key []byte) error {
+	if err := w.batch.Delete(key); err != nil {
+		return err
+	}
+	return w.added()
+}
+
+func (w *BatchWriter) added() error {
+	if w.mode == writeModeBatch {
+		return nil
+	}
+	w.pending++
+	if w.pending >= w.batchSize || w.mode == writeModeOp {
+		return w.commit()
+	}
+	return nil
+}
+
+// commit writes out the pending entries of a batchn, txn or op writer.
+func (w *BatchWriter) commit() error {
+	if w.pending == 0 {
+		return nil
+	}
+	w.pending = 0
+	return w.batch.Commit()
+}
+
+func (w *BatchWriter) Flush() error {
+	var err error
+	if w.mode == writeModeBatch {
+		err = w.batch.Commit()
+	} else {
+		err = w.commit()
+	}
+	w.batch.Cancel()
+	return err
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"badgerBench/bDB"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync/atomic"
+)
+
+// ======================================
+//
+//	YCSB core workloads
+//
+// ======================================
+
+// YCSB operation types, also used as the per-op histogram names.
+const (
+	ycsbRead   = "read"
+	ycsbUpdate = "update"
+	ycsbInsert = "insert"
+	ycsbScan   = "scan"
+	ycsbRMW    = "rmw"
+)
+
+// Max number of records visited by a scan; the length is uniform in [1, max].
+const kYCSBMaxScanLength = 100
+
+type YCSBWorkload struct {
+	read   float64
+	update float64
+	insert float64
+	scan   float64
+	rmw    float64
+	dist   string // request distribution, unless --key_dist is set
+}
+
+var ycsbWorkloads = map[string]YCSBWorkload{
+	// update heavy
+	"ycsba": {read: 0.5, update: 0.5, dist: keyDistZipfian},
+	// read mostly
+	"ycsbb": {read: 0.95, update: 0.05, dist: keyDistZipfian},
+	// read only
+	"ycsbc": {read: 1, dist: keyDistZipfian},
+	// read latest
+	"ycsbd": {read: 0.95, insert: 0.05, dist: keyDistLatest},
+	// short ranges
+	"ycsbe": {scan: 0.95, insert: 0.05, dist: keyDistZipfian},
+	// read-modify-write
+	"ycsbf": {read: 0.5, rmw: 0.5, dist: keyDistZipfian},
+}
+
+func (w *YCSBWorkload) NextOp(rd *rand.Rand) string {
+	p := rd.Float64()
+	if p -= w.read; p < 0 {
+		return ycsbRead
+	}
+	if p -= w.update; p < 0 {
+		return ycsbUpdate
+	}
+	if p -= w.insert; p < 0 {
+		return ycsbInsert
+	}
+	if p -= w.scan; p < 0 {
+		return ycsbScan
+	}
+	return ycsbRMW
+}
+
+// ycsbNextKey picks an existing key following the request distribution.
+// Keys inserted by earlier ycsb ops are part of the key space.
+func (bm *Benchmark) ycsbNextKey(thread *ThreadState) int {
+	return thread.NextKey(FLAGS_num + int(atomic.LoadInt64(&bm.inserted)))
+}
+
+func (bm *Benchmark) YCSB(thread *ThreadState, w YCSBWorkload) {
+	var bytes int64 = 0
+	rnd := thread.NewRand("values")
+	values := NewValueGenerator(rnd, bm.valueSize)
+	dist := KeyDist(w.dist)
+	reads, found := 0, 0
+	for i := 0; i < bm.reads; i++ {
+		op := w.NextOp(thread.rd)
+		var err error
+		switch op {
+		case ycsbRead:
+			key := GenKey(bm.ycsbNextKey(thread))
+			var v string
+			reads++
+			if v, err = bm.engine.Get(key); err == nil {
+				found++
+				bytes += int64(len(key) + len(v))
+			} else if err == bDB.ErrNotFound {
+				err = nil
+			}
+		case ycsbUpdate:
+			key := GenKey(bm.ycsbNextKey(thread))
+			value := values.Next()
+			err = bm.engine.Put(key, string(value))
+			bytes += int64(len(key) + len(value))
+		case ycsbInsert:
+			key := GenKey(FLAGS_num + int(atomic.AddInt64(&bm.inserted, 1)-1))
+			value := values.Next()
+			err = bm.engine.Put(key, string(value))
+			bytes += int64(len(key) + len(value))
+		case ycsbScan:
+			key := GenKey(bm.ycsbNextKey(thread))
+			length := 1 + thread.rd.Intn(kYCSBMaxScanLength)
+			var n int64
+			n, err = bm.ycsbScan(key, length)
+			bytes += n
+		case ycsbRMW:
+			key := GenKey(bm.ycsbNextKey(thread))
+			value := values.Next()
+			if _, err = bm.engine.Get(key); err == nil || err == bDB.ErrNotFound {
+				err = bm.engine.Put(key, string(value))
+			}
+			bytes += int64(len(key) + len(value))
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ycsb %s error: %s\n", op, err.Error())
+			os.Exit(1)
+		}
+		thread.stats.FinishedTypedOp(op)
+	}
+	thread.stats.AddBytes(bytes)
+	thread.stats.AddMsg(fmt.Sprintf("(%s)", dist))
+	thread.stats.AddCounter("reads", int64(reads))
+	thread.stats.AddCounter("found", int64(found))
+	thread.stats.SetSummary(func(s *Stats) string {
+		if s.counters["reads"] == 0 {
+			return ""
+		}
+		return fmt.Sprintf("(%d of %d reads found)", s.counters["found"], s.counters["reads"])
+	})
+}
+
+// ycsbScan reads up to length records starting at key and returns the
+// number of bytes read.
+func (bm *Benchmark) ycsbScan(key string, length int) (int64, error) {
+	var bytes int64 = 0
+	iter := bm.engine.NewIterator(bDB.IteratorOptions{PrefetchValues: true, PrefetchSize: length})
+	defer iter.Close()
+	i := 0
+	for iter.Seek([]byte(key)); i < length && iter.Valid(); iter.Next() {
+		bytes += int64(len(iter.Key()))
+		err := iter.Value(func(v []byte) error {
+			bytes += int64(len(v))
+			return nil
+		})
+		if err != nil {
+			return bytes, err
+		}
+		i++
+	}
+	return bytes, nil
+}